@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NodeBalance is a node whose in and out degree differ
+// Surplus is the out degree minus the in degree
+type NodeBalance struct {
+	node    *Node
+	Surplus int
+}
+
+// BalanceReport describes whether a graph has an Eulerian path, and why not if it does not
+type BalanceReport struct {
+	Unbalanced []NodeBalance // Every node whose in and out degree differ
+	Components [][]*Node     // Weakly connected components of the nodes that have edges
+	Eulerian   bool
+	Reason     string // Why the graph has no Eulerian path, empty if it has one
+}
+
+// AnalyzeBalance reports the unbalanced nodes and connected components of the graph and whether it has an Eulerian path
+// A graph has an Eulerian path if its edges are in one connected component and either every node is balanced,
+// or one node has one more edge out than in and another has one more edge in than out
+func (g *Graph) AnalyzeBalance() *BalanceReport {
+	report := &BalanceReport{Components: g.Components()}
+
+	var plusOne, minusOne, other int
+	for _, n := range g.nodes {
+		surplus := g.outDegree[n] - g.inDegree[n]
+		if surplus == 0 {
+			continue
+		}
+		report.Unbalanced = append(report.Unbalanced, NodeBalance{n, surplus})
+		switch surplus {
+		case 1:
+			plusOne++
+		case -1:
+			minusOne++
+		default:
+			other++
+		}
+	}
+
+	var reasons []string
+	if len(report.Components) == 0 {
+		reasons = append(reasons, "graph has no edges")
+	} else if len(report.Components) > 1 {
+		reasons = append(reasons, fmt.Sprintf("edges are split over %d connected components", len(report.Components)))
+	}
+	if other > 0 || plusOne > 1 || minusOne > 1 || plusOne != minusOne {
+		reasons = append(reasons, fmt.Sprintf("%d nodes are unbalanced: %s", len(report.Unbalanced), report.unbalancedString(10)))
+	}
+	report.Eulerian = len(reasons) == 0
+	report.Reason = strings.Join(reasons, "; ")
+	return report
+}
+
+// unbalancedString lists up to max unbalanced nodes with their surplus
+func (report *BalanceReport) unbalancedString(max int) string {
+	str := make([]string, 0, max+1)
+	for i, nb := range report.Unbalanced {
+		if i == max {
+			str = append(str, fmt.Sprintf("and %d more", len(report.Unbalanced)-max))
+			break
+		}
+		str = append(str, fmt.Sprintf("%s (%+d)", nb.node.value, nb.Surplus))
+	}
+	return strings.Join(str, ", ")
+}
+
+// Components returns the weakly connected components of the graph, ignoring nodes with no edges
+// Components are listed in the order their first node was added to the graph
+func (g *Graph) Components() [][]*Node {
+	// Union-find over the nodes joined by each edge
+	parent := make(map[*Node]*Node)
+	find := func(n *Node) *Node {
+		for parent[n] != n {
+			parent[n] = parent[parent[n]]
+			n = parent[n]
+		}
+		return n
+	}
+	for _, e := range g.edges {
+		u, v := g.GetNodeFromValue(e.start.value), g.GetNodeFromValue(e.end.value)
+		if u == nil || v == nil {
+			continue
+		}
+		for _, n := range []*Node{u, v} {
+			if _, ok := parent[n]; !ok {
+				parent[n] = n
+			}
+		}
+		if ru, rv := find(u), find(v); ru != rv {
+			parent[ru] = rv
+		}
+	}
+
+	componentInx := make(map[*Node]int)
+	components := make([][]*Node, 0)
+	for _, n := range g.nodes {
+		if _, ok := parent[n]; !ok {
+			continue
+		}
+		root := find(n)
+		inx, ok := componentInx[root]
+		if !ok {
+			inx = len(components)
+			componentInx[root] = inx
+			components = append(components, nil)
+		}
+		components[inx] = append(components[inx], n)
+	}
+	return components
+}
+
+// EulerianPaths returns a set of paths that together walk every edge of the graph as many times as its weight
+// Each component is balanced by adding virtual edges from nodes with more edges in than out to nodes with more edges
+// out than in. The Eulerian circuit of the balanced component is then cut at the virtual edges, so a component
+// with a surplus of s edges gives s paths and a balanced component gives one.
+func (g *Graph) EulerianPaths(order TraversalOrder) ([][]*Node, [][]*Edge) {
+	var nodePaths [][]*Node
+	var edgePaths [][]*Edge
+	for _, e := range g.edges {
+		e.traversed = 0
+	}
+
+	for _, component := range g.Components() {
+		// Pair each missing out edge with a missing in edge
+		var sources, sinks []*Node
+		for _, n := range component {
+			for i := g.outDegree[n] - g.inDegree[n]; i > 0; i-- {
+				sources = append(sources, n)
+			}
+			for i := g.inDegree[n] - g.outDegree[n]; i > 0; i-- {
+				sinks = append(sinks, n)
+			}
+		}
+		virtual := make(map[*Node][]*Edge)
+		isVirtual := make(map[*Edge]bool)
+		for i := 0; i < len(sources) && i < len(sinks); i++ {
+			e := &Edge{start: sinks[i], end: sources[i], weight: 1}
+			virtual[sinks[i]] = append(virtual[sinks[i]], e)
+			isVirtual[e] = true
+		}
+
+		start := component[0]
+		if len(sources) > 0 {
+			start = sources[0]
+		} else {
+			for _, n := range component {
+				if g.outDegree[n] > 0 {
+					start = n
+					break
+				}
+			}
+		}
+		_, circuit := g.walkEdges(start, order, virtual)
+
+		// Rotate the circuit to begin just after a virtual edge, then cut it at every virtual edge
+		rotated := make([]*Edge, 0, len(circuit)+1)
+		for i, e := range circuit {
+			if isVirtual[e] {
+				rotated = append(append(rotated, circuit[i+1:]...), circuit[:i+1]...)
+				break
+			}
+		}
+		if len(rotated) == 0 {
+			rotated = append(rotated, circuit...)
+		}
+		var path []*Edge
+		for _, e := range append(rotated, nil) {
+			if e != nil && !isVirtual[e] {
+				path = append(path, e)
+				continue
+			}
+			if len(path) > 0 {
+				nodes := []*Node{g.GetNodeFromValue(path[0].start.value)}
+				for _, pe := range path {
+					nodes = append(nodes, g.GetNodeFromValue(pe.end.value))
+				}
+				nodePaths = append(nodePaths, nodes)
+				edgePaths = append(edgePaths, path)
+			}
+			path = nil
+		}
+	}
+	return nodePaths, edgePaths
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// BiNode is a node in a bidirected de Bruijn graph
+// A (l-1)-mer and its reverse complement share one node whose value is the smaller of the two
+type BiNode struct {
+	value string
+}
+
+// BiEdge is an l-tuple in a bidirected de Bruijn graph stored in its canonical orientation
+// startRC and endRC are true when the prefix and suffix of value are the reverse complements of the start and end node values
+type BiEdge struct {
+	start, end     *BiNode
+	startRC, endRC bool
+	value          string
+	weight         int
+}
+
+// BiGraph is a bidirected de Bruijn graph in which both strands of the reads contribute to one set of nodes and edges
+type BiGraph struct {
+	nodes        []*BiNode
+	edges        []*BiEdge
+	nodeValueMap map[string]*BiNode
+	edgeValueMap map[string]*BiEdge
+}
+
+// orientedEdge is a BiEdge traversed in one direction. If rc is true the edge spells the reverse complement of its value
+type orientedEdge struct {
+	edge *BiEdge
+	rc   bool
+}
+
+// NewBiGraph returns a BiGraph with initialized attributes
+func NewBiGraph() *BiGraph {
+	return &BiGraph{make([]*BiNode, 0), make([]*BiEdge, 0), make(map[string]*BiNode), make(map[string]*BiEdge)}
+}
+
+// Canonical returns the smaller of a sequence and its reverse complement, and true if that is the reverse complement
+func Canonical(s string) (string, bool) {
+	rc := ReverseComplement(s)
+	if rc < s {
+		return rc, true
+	}
+	return s, false
+}
+
+// MakeBidirectedGraph returns a bidirected de Bruijn graph of the l-tuples in a list of reads
+// Each l-tuple and its reverse complement are counted as the same edge
+func MakeBidirectedGraph(reads []string, l int) *BiGraph {
+	g := NewBiGraph()
+	for _, read := range reads {
+		for _, lTup := range GenerateReadLTuples(read, l) {
+			g.AddLTuple(lTup)
+		}
+	}
+	return g
+}
+
+/*
+	BiGraph Methods
+*/
+
+// NumNodes returns the number of nodes in the graph
+func (g *BiGraph) NumNodes() int {
+	return len(g.nodes)
+}
+
+// NumEdges returns the number of edges in the graph
+func (g *BiGraph) NumEdges() int {
+	return len(g.edges)
+}
+
+// addNode returns the node for an (l-1)-mer, adding it to the graph if it is not present
+// The second return value is true if the (l-1)-mer is the reverse complement of the node value
+func (g *BiGraph) addNode(s string) (*BiNode, bool) {
+	value, rc := Canonical(s)
+	n, ok := g.nodeValueMap[value]
+	if !ok {
+		n = &BiNode{value}
+		g.nodes = append(g.nodes, n)
+		g.nodeValueMap[value] = n
+	}
+	return n, rc
+}
+
+// AddLTuple adds an l-tuple to the graph in its canonical orientation
+// If the l-tuple or its reverse complement is already in the graph the edge weight is incremented by 1
+func (g *BiGraph) AddLTuple(lTup string) {
+	value, _ := Canonical(lTup)
+	if e, ok := g.edgeValueMap[value]; ok {
+		e.weight++
+		return
+	}
+	e := &BiEdge{value: value, weight: 1}
+	e.start, e.startRC = g.addNode(value[:len(value)-1])
+	e.end, e.endRC = g.addNode(value[1:])
+	g.edges = append(g.edges, e)
+	g.edgeValueMap[value] = e
+}
+
+// GetEdge returns the edge for an l-tuple in either orientation, or nil if it is not in the graph
+func (g *BiGraph) GetEdge(lTup string) *BiEdge {
+	value, _ := Canonical(lTup)
+	return g.edgeValueMap[value]
+}
+
+// edgesFrom returns the edges leaving the oriented (l-1)-mer s
+// The edges entering s are the edges leaving the reverse complement of s
+func (g *BiGraph) edgesFrom(s string) []orientedEdge {
+	out := make([]orientedEdge, 0, 4)
+	for _, b := range "ACGT" {
+		value, rc := Canonical(s + string(b))
+		if e, ok := g.edgeValueMap[value]; ok {
+			out = append(out, orientedEdge{e, rc})
+		}
+	}
+	return out
+}
+
+// spell returns the sequence of an oriented edge
+func (oe orientedEdge) spell() string {
+	if oe.rc {
+		return ReverseComplement(oe.edge.value)
+	}
+	return oe.edge.value
+}
+
+// extend appends edges to seq while the node at the end of seq has exactly one edge in and one edge out
+// k is the length of the nodes
+func (g *BiGraph) extend(seq string, k int, visited map[*BiEdge]bool, weights *int, edges *int) string {
+	for {
+		s := seq[len(seq)-k:]
+		out := g.edgesFrom(s)
+		if len(out) != 1 || len(g.edgesFrom(ReverseComplement(s))) != 1 || visited[out[0].edge] {
+			return seq
+		}
+		next := out[0]
+		visited[next.edge] = true
+		*weights += next.edge.weight
+		*edges++
+		seq += next.spell()[k:]
+	}
+}
+
+// Unitigs returns the maximal non-branching paths of the graph as contigs
+// Each contig is reported once in its canonical orientation, so both strands of a region give the same contig
+func (g *BiGraph) Unitigs() []*Contig {
+	if len(g.edges) == 0 {
+		return nil
+	}
+	// Visit edges in sorted order so the contigs do not depend on the order of the reads
+	sorted := make([]*BiEdge, len(g.edges))
+	copy(sorted, g.edges)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].value < sorted[j].value })
+
+	visited := make(map[*BiEdge]bool)
+	contigs := make([]*Contig, 0)
+	for _, e := range sorted {
+		if visited[e] {
+			continue
+		}
+		visited[e] = true
+		weights, edges := e.weight, 1
+
+		// Extend forwards, then extend the reverse complement to extend backwards
+		k := len(e.value) - 1
+		seq := g.extend(e.value, k, visited, &weights, &edges)
+		seq = g.extend(ReverseComplement(seq), k, visited, &weights, &edges)
+		seq, _ = Canonical(seq)
+
+		contigs = append(contigs, &Contig{Seq: seq, Coverage: float64(weights) / float64(edges)})
+	}
+
+	SortContigs(contigs)
+	for i, c := range contigs {
+		c.ID = fmt.Sprintf("unitig_%d", i+1)
+	}
+	return contigs
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Bubble is a pair of parallel paths popped by PopBubbles, such as the two alleles of a heterozygous SNP
+type Bubble struct {
+	Start, End     string // Values of the nodes where the paths split and join
+	Kept, Popped   string // Sequences of the kept and removed paths, from Start to End
+	KeptCoverage   float64
+	PoppedCoverage float64
+	kept, popped   []*Edge
+}
+
+// PopBubbles removes the weaker of any parallel non-branching paths of fewer than maxLen bases between two nodes
+// The path with the highest mean weight is kept, with ties going to the lowest sequence, and the others are deleted
+// along with their inner nodes. Popping bubbles can merge paths into new bubbles, so the graph is searched again until
+// no bubble is found. Returns the popped paths in the order they were removed.
+func (g *Graph) PopBubbles(maxLen int) []*Bubble {
+	var popped []*Bubble
+	for {
+		// Group the non-branching paths by the nodes they join
+		type ends struct{ start, end string }
+		parallel := make(map[ends][][]*Edge)
+		var order []ends
+		for _, path := range g.unitigPaths() {
+			key := ends{path[0].start.value, path[len(path)-1].end.value}
+			if key.start == key.end || len(pathSequence(path)) >= maxLen {
+				continue
+			}
+			if _, ok := parallel[key]; !ok {
+				order = append(order, key)
+			}
+			parallel[key] = append(parallel[key], path)
+		}
+
+		var found []*Bubble
+		for _, key := range order {
+			paths := parallel[key]
+			if len(paths) < 2 {
+				continue
+			}
+			best := 0
+			for i, path := range paths[1:] {
+				cov, bestCov := pathCoverage(path), pathCoverage(paths[best])
+				if cov > bestCov || cov == bestCov && pathSequence(path) < pathSequence(paths[best]) {
+					best = i + 1
+				}
+			}
+			for i, path := range paths {
+				if i == best {
+					continue
+				}
+				found = append(found, &Bubble{key.start, key.end, pathSequence(paths[best]), pathSequence(path),
+					pathCoverage(paths[best]), pathCoverage(path), paths[best], path})
+				g.deletePath(path)
+			}
+		}
+		if len(found) == 0 {
+			return popped
+		}
+		popped = append(popped, found...)
+	}
+}
+
+// WriteVariants writes the kept and popped paths of each bubble, so the variants they hold are not lost
+func WriteVariants(w io.Writer, bubbles []*Bubble) {
+	fmt.Fprintln(w, "Start\tEnd\tKept\tKept coverage\tPopped\tPopped coverage")
+	for _, b := range bubbles {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.2f\t%s\t%.2f\n", b.Start, b.End, b.Kept, b.KeptCoverage, b.Popped, b.PoppedCoverage)
+	}
+}
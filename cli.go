@@ -0,0 +1,788 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Options holds the settings shared by every subcommand
+type Options struct {
+	Inputs      []string       // Read files to load
+	R1, R2      []string       // Paired read files, R1[i] is the mate file of R2[i]
+	Interleaved []string       // Paired read files with each R1 record followed by its R2 record
+	MinOverlap  int            // Shortest overlap between mates used to measure the insert size
+	L           int            // Length of the l-tuples (k-mers) used as graph edges
+	OutDir      string         // Directory outputs are written to, stdout if empty
+	Formats     []string       // Output formats to write
+	Save        string         // Optional path prefix to save the unique l-tuples to
+	Wrap        int            // Line width of FASTA output, 0 for no wrapping
+	Order       TraversalOrder // Order the Eulerian walk tries the edges leaving each node
+	Compact     bool           // Perform the x,y-detachments on the compacted graph
+	Canonical   bool           // Build a bidirected graph in which each l-tuple and its reverse complement share an edge
+	Trim        TrimOptions    // Adapter clipping, quality trimming and filtering of the reads
+	Correct     bool           // Correct the reads against the solid l-tuples before building the graph
+	TipLen      int            // Clip dead-end paths shorter than this many bases before reducing, 0 to disable
+	BubbleLen   int            // Pop bubbles with paths shorter than this many bases before reducing, 0 to disable
+	ConnCount   int            // Highest weight of an erroneous connection, 0 for no limit
+	ConnRatio   float64        // Highest weight of an erroneous connection relative to its heaviest sibling, 0 for no limit
+	MinSolid    int            // Count at which an l-tuple is solid, 0 to take it from the count histogram
+	Check       bool           // Validate the graph after every reduction step and stop at the first inconsistency
+	Highlight   string         // Path drawn in red in the DOT output: "walk" for the Eulerian walk, or the number of a read path
+}
+
+// command is a subcommand of the assembler
+type command struct {
+	name    string
+	summary string
+	formats []string // Output formats the command can write
+	run     func(opts *Options) error
+}
+
+// stringList is a flag.Value that collects repeated flags and comma separated values
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*s = append(*s, part)
+		}
+	}
+	return nil
+}
+
+// commands returns the subcommands of the assembler keyed by name
+func commands() map[string]*command {
+	cmds := []*command{
+		{"assemble", "build the graph, find an Eulerian walk and reduce the read paths", []string{"txt", "fasta", "dot"}, runAssemble},
+		{"count-kmers", "write the l-tuples found in the reads with their counts", []string{"txt"}, runCountKmers},
+		{"graph", "write the edges of the de Bruijn graph", []string{"txt", "dot"}, runGraph},
+		{"reduce", "perform x,y-detachments until every read path is a single edge", []string{"txt", "fasta", "dot"}, runReduce},
+		{"stats", "print summary statistics for the reads and graph", []string{"txt"}, runStats},
+		{"stats kmers", "print the l-tuple count histogram and the coverage and genome size it implies", []string{"txt"}, runStatsKmers},
+	}
+	cmdMap := make(map[string]*command)
+	for _, cmd := range cmds {
+		cmdMap[cmd.name] = cmd
+	}
+	return cmdMap
+}
+
+// usage writes the list of subcommands to w
+func usage(w io.Writer) {
+	cmds := commands()
+	names := make([]string, 0, len(cmds))
+	for name := range cmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Usage: GenomeAssembler <command> -in reads.fastq -k <l> [-out dir] [-format txt,fasta,dot]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-12s %s\n", name, cmds[name].summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'GenomeAssembler <command> -h' for the flags of a command.")
+}
+
+// run parses the command line arguments and runs the requested subcommand
+func run(args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage(os.Stderr)
+		if len(args) == 0 {
+			return errors.New("no command given")
+		}
+		return nil
+	}
+
+	cmd, ok := commands()[args[0]]
+	if !ok {
+		usage(os.Stderr)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	// Commands such as "stats kmers" are named by two words
+	if len(args) > 1 {
+		if sub, ok := commands()[args[0]+" "+args[1]]; ok {
+			cmd, args = sub, args[1:]
+		}
+	}
+
+	opts, err := parseOptions(cmd, args[1:])
+	if err == flag.ErrHelp {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return cmd.run(opts)
+}
+
+// parseOptions parses and validates the flags of a subcommand
+// Input files may be given with -in or as positional arguments
+func parseOptions(cmd *command, args []string) (*Options, error) {
+	opts := &Options{}
+	var inputs, formats, r1, r2, interleaved, adapters stringList
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Var(&inputs, "in", "input FASTA or FASTQ file, may be repeated or comma separated")
+	fs.Var(&r1, "r1", "R1 file of a paired-end library, may be repeated")
+	fs.Var(&r2, "r2", "R2 file of a paired-end library, given in the same order as -r1")
+	fs.Var(&interleaved, "interleaved", "interleaved paired-end FASTA or FASTQ file, may be repeated")
+	fs.IntVar(&opts.MinOverlap, "min-overlap", 10, "shortest mate overlap used to estimate the insert size")
+	fs.IntVar(&opts.L, "k", 0, "length of the l-tuples (k-mers) used as edges")
+	fs.StringVar(&opts.OutDir, "out", "", "directory to write outputs to (default stdout)")
+	fs.Var(&formats, "format", "output formats, comma separated: "+strings.Join(cmd.formats, ", ")+" (default txt)")
+	fs.StringVar(&opts.Save, "save", "", "path prefix to save the unique l-tuples to")
+	seed := fs.Int64("seed", 0, "shuffle the edges tried by the Eulerian walk with this seed instead of using lexicographic order")
+	fs.BoolVar(&opts.Compact, "compact", false, "reduce read paths on the compacted graph, with one edge per unitig")
+	fs.BoolVar(&opts.Canonical, "canonical", false, "merge each l-tuple with its reverse complement in a bidirected graph")
+	fs.IntVar(&opts.Wrap, "wrap", 60, "line width of FASTA output, 0 to disable wrapping")
+	fs.IntVar(&opts.Trim.MinQual, "trim-qual", 0, "trim reads at the first window with a mean quality below this, 0 to disable")
+	fs.IntVar(&opts.Trim.Window, "trim-window", 4, "length of the sliding window used by -trim-qual")
+	fs.IntVar(&opts.Trim.MinLen, "min-len", 0, "remove reads shorter than this after trimming (default the l-tuple length)")
+	fs.IntVar(&opts.Trim.MaxN, "max-n", -1, "remove reads with more than this many Ns, negative for no limit")
+	fs.Var(&adapters, "adapter", "adapter sequence to clip from the 3' end of reads, may be repeated or comma separated")
+	fs.IntVar(&opts.TipLen, "tip-len", 0, "clip dead-end paths shorter than this many bases before reducing read paths, 0 to disable")
+	fs.IntVar(&opts.BubbleLen, "bubble-len", 0, "pop bubbles whose paths are shorter than this many bases before reducing read paths, 0 to disable")
+	fs.IntVar(&opts.ConnCount, "conn-count", 0, "remove edges at branching nodes with at most this weight, 0 for no limit")
+	fs.Float64Var(&opts.ConnRatio, "conn-ratio", 0, "remove edges at branching nodes lighter than this fraction of their heaviest sibling, 0 for no limit")
+	fs.BoolVar(&opts.Correct, "correct", false, "correct read errors by spectral alignment before building the graph")
+	fs.IntVar(&opts.MinSolid, "solid", 0, "count at which an l-tuple is solid for -correct (default from the count histogram)")
+	fs.BoolVar(&opts.Check, "check-invariants", false, "validate the graph after every reduction step and report the first inconsistency")
+	fs.StringVar(&opts.Highlight, "highlight", "", "path to draw in red in the dot output: walk for the Eulerian walk, or the number of a read path")
+
+	// Flags may follow positional arguments, so keep parsing after each one
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	opts.Inputs = append(inputs, positional...)
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			opts.Order = TraversalOrder{Random: true, Seed: *seed}
+		}
+	})
+	opts.R1, opts.R2, opts.Interleaved = r1, r2, interleaved
+	opts.Trim.Adapters = adapters
+	if opts.Trim.MinLen < opts.L {
+		opts.Trim.MinLen = opts.L
+	}
+	opts.Formats = formats
+	if len(opts.Formats) == 0 {
+		opts.Formats = []string{"txt"}
+	}
+
+	if len(opts.Inputs) == 0 && len(opts.R1) == 0 && len(opts.Interleaved) == 0 {
+		return nil, errors.New("no input files given")
+	}
+	if len(opts.R1) != len(opts.R2) {
+		return nil, fmt.Errorf("%d -r1 files given for %d -r2 files", len(opts.R1), len(opts.R2))
+	}
+	if opts.L < 2 {
+		return nil, fmt.Errorf("-k must be at least 2, got %d", opts.L)
+	}
+	for _, f := range opts.Formats {
+		if !containsString(cmd.formats, f) {
+			return nil, fmt.Errorf("%s does not support output format %q", cmd.name, f)
+		}
+	}
+	if opts.hasFormat("dot") && opts.Canonical {
+		return nil, errors.New("dot output is not supported with -canonical")
+	}
+	if opts.Highlight != "" && opts.Highlight != "walk" {
+		if n, err := strconv.Atoi(opts.Highlight); err != nil || n < 0 {
+			return nil, fmt.Errorf("-highlight must be walk or a read path number, got %q", opts.Highlight)
+		}
+	}
+	if opts.OutDir != "" {
+		if err := os.MkdirAll(opts.OutDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	return opts, nil
+}
+
+// containsString returns true if s is in list
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// nopWriteCloser lets stdout be used as an output without being closed
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// createOutput creates the file name inside the output directory, or returns stdout if no directory was given
+func (opts *Options) createOutput(name string) (io.WriteCloser, error) {
+	if opts.OutDir == "" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.Create(filepath.Join(opts.OutDir, name))
+}
+
+// writeOutput creates an output and passes it to write, closing it afterwards
+func (opts *Options) writeOutput(name string, write func(w io.Writer) error) error {
+	w, err := opts.createOutput(name)
+	if err != nil {
+		return err
+	}
+	if err := write(w); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// writeLog writes a report to the file name inside the output directory, or to stderr if no directory was given
+// Reports from the stages before the graph is built go here so they do not mix with the output on stdout
+func (opts *Options) writeLog(name string, write func(w io.Writer)) error {
+	if opts.OutDir == "" {
+		write(os.Stderr)
+		return nil
+	}
+	return opts.writeOutput(name, func(w io.Writer) error {
+		write(w)
+		return nil
+	})
+}
+
+// hasFormat returns true if the output format f was requested
+func (opts *Options) hasFormat(f string) bool {
+	return containsString(opts.Formats, f)
+}
+
+// loadReads returns the reads from every input file and the read pairs from every paired-end library
+// The mates are at the end of the list of reads in the order laid out by PairedReads, and the pairs hold their
+// sequences after preprocessing.
+// Reads are trimmed and filtered with opts.Trim, split at ambiguous bases, then corrected if opts.Correct is set.
+func (opts *Options) loadReads() ([]string, []*ReadPair, error) {
+	var recs []*SeqRecord
+	for _, filename := range opts.Inputs {
+		fileRecs, err := ReadRecords(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		recs = append(recs, fileRecs...)
+	}
+
+	var pairs []*ReadPair
+	for i := range opts.R1 {
+		libPairs, err := ReadPairedFiles(opts.R1[i], opts.R2[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		pairs = append(pairs, libPairs...)
+	}
+	for _, filename := range opts.Interleaved {
+		libPairs, err := ReadInterleavedFile(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		pairs = append(pairs, libPairs...)
+	}
+
+	// Reads too short to hold an l-tuple are always removed, so the trimming report is only written if it says more
+	recs, report := TrimRecords(recs, opts.Trim)
+	pairs, pairReport := TrimPairs(pairs, opts.Trim)
+	report.Merge(pairReport)
+	if report.Kept < report.Reads || report.BasesRemoved > 0 {
+		err := opts.writeLog("trimming.txt", func(w io.Writer) {
+			WriteTrimReport(w, report)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	reads := RecordSeqs(recs)
+	reads = append(reads, PairedReads(pairs)...)
+
+	reads, ambiguity := SplitReads(reads, len(reads)-2*len(pairs), opts.L)
+	if ambiguity.SoftMasked > 0 || ambiguity.Ambiguous > 0 {
+		err := opts.writeLog("ambiguous.txt", func(w io.Writer) {
+			WriteAmbiguityReport(w, ambiguity)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if opts.Correct {
+		var report *CorrectionReport
+		reads, report = CorrectReads(reads, opts.L, opts.MinSolid)
+		err := opts.writeLog("correction.txt", func(w io.Writer) {
+			WriteCorrectionReport(w, report)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return reads, UpdatePairs(pairs, reads), nil
+}
+
+/*
+	Subcommands
+*/
+
+// writePathSet writes the nodes and sequence of every read path in a path set
+func writePathSet(w io.Writer, title string, ps *PathSet) {
+	fmt.Fprintln(w, title)
+	for i, path := range *ps {
+		fmt.Fprintf(w, "Read Path %d Nodes: %s\n", i, ReadPathNodesString(path))
+		fmt.Fprintf(w, "Read Path %d Sequence: %s\n", i, ReadPathSequence(path))
+		fmt.Fprintln(w)
+	}
+}
+
+func runAssemble(opts *Options) error {
+	reads, _, err := opts.loadReads()
+	if err != nil {
+		return err
+	}
+	if opts.Canonical {
+		return assembleCanonical(opts, reads)
+	}
+	G, _, fwPathSet := DebruinizeReads(reads, opts.L, opts.Save)
+
+	walks, walkEdges, balance := eulerianWalks(G, opts.Order)
+	unitigs := G.Unitigs()
+	var origPaths strings.Builder
+	writePathSet(&origPaths, "Original Read Path Set", fwPathSet)
+	// The graph and read paths are changed by the reduction, so the DOT output of the graph is written first
+	if err := opts.writeGraphDot(G, fwPathSet, walkEdges); err != nil {
+		return err
+	}
+
+	redEdges, redFwPathSet, err := opts.reduce(G, fwPathSet)
+	if err != nil {
+		return err
+	}
+
+	if opts.hasFormat("txt") {
+		err := opts.writeOutput("assembly.txt", func(w io.Writer) error {
+			fmt.Fprintln(w, "Traversal Order:", opts.Order)
+			if balance.Eulerian {
+				fmt.Fprintln(w, "Eulerian Walk:", walks[0].Seq)
+			} else {
+				fmt.Fprintln(w, "No Eulerian Path:", balance.Reason)
+				for i, walk := range walks {
+					fmt.Fprintf(w, "Walk %d: %s\n", i+1, walk.Seq)
+				}
+			}
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "Unitigs")
+			for _, c := range unitigs {
+				fmt.Fprintf(w, "%s\t%s\t%.2f\n", c.ID, c.Seq, c.Coverage)
+			}
+			fmt.Fprintln(w)
+			io.WriteString(w, origPaths.String())
+			fmt.Fprintln(w)
+			writePathSet(w, "Reduced Read Path Set", redFwPathSet)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if opts.hasFormat("fasta") {
+		err := opts.writeOutput("unitigs.fasta", func(w io.Writer) error {
+			return WriteFasta(w, unitigs, opts.Wrap)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return opts.writeReduced(walks, redEdges, redFwPathSet)
+}
+
+// writeGraphDot writes G to graph.dot if the dot format was chosen
+// walkEdges are the edges of the Eulerian walks of G; if they are nil and -highlight walk needs them, they are found here
+func (opts *Options) writeGraphDot(G *Graph, ps *PathSet, walkEdges []*Edge) error {
+	if !opts.hasFormat("dot") {
+		return nil
+	}
+	if walkEdges == nil && opts.Highlight == "walk" {
+		_, walkEdges, _ = eulerianWalks(G, opts.Order)
+	}
+	return opts.writeDot("graph.dot", "debruijn", G.edges, opts.highlight(walkEdges, ps))
+}
+
+// writeReduced writes the walks and the edges left by a reduction to contigs.fasta, and the reduced graph to reduced.dot,
+// in whichever of the two formats were chosen
+func (opts *Options) writeReduced(walks []*Contig, redEdges []*Edge, ps *PathSet) error {
+	if opts.hasFormat("fasta") {
+		contigs := append(walks, EdgeContigs(redEdges, "contig")...)
+		err := opts.writeOutput("contigs.fasta", func(w io.Writer) error {
+			return WriteFasta(w, contigs, opts.Wrap)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if opts.hasFormat("dot") {
+		return opts.writeDot("reduced.dot", "reduced", redEdges, opts.highlight(nil, ps))
+	}
+	return nil
+}
+
+// writeDot writes edges as a Graphviz digraph to the output name, with the highlighted edges drawn in red
+func (opts *Options) writeDot(name, graphName string, edges, highlight []*Edge) error {
+	return opts.writeOutput(name, func(w io.Writer) error {
+		return WriteDot(w, graphName, edges, highlight)
+	})
+}
+
+// highlight returns the edges chosen by -highlight: the edges of the Eulerian walks, or the edges of the numbered read path of ps
+// It returns nil if nothing was chosen or the read path does not exist
+func (opts *Options) highlight(walkEdges []*Edge, ps *PathSet) []*Edge {
+	if opts.Highlight == "walk" {
+		return walkEdges
+	}
+	n, err := strconv.Atoi(opts.Highlight)
+	if err != nil || ps == nil || n < 0 || n >= len(*ps) {
+		return nil
+	}
+	return (*ps)[n].Edges()
+}
+
+// reduce performs x,y-detachments until every read path is a single edge and returns the edges of the reduced graph
+// The graph is cleaned of tips, bubbles and erroneous connections first, and the read paths cut down to the edges left.
+// With -compact the detachments are made on the compacted graph of G. If reads had to be cut or tangles are left,
+// the superpath report is written to superpaths.txt, or to stderr without -out.
+// With -check-invariants the graph is validated after cleaning and after every detachment, and the first
+// inconsistency is returned as an error naming the step and read path that caused it
+func (opts *Options) reduce(G *Graph, ps *PathSet) ([]*Edge, *PathSet, error) {
+	G.SetInOutDegree()
+	if err := opts.clean(G, ps); err != nil {
+		return nil, nil, err
+	}
+	if opts.Check {
+		if err := G.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("after cleaning: %w", err)
+		}
+	}
+	ps.RemoveMissingEdges(G)
+	if opts.Compact {
+		cg := Compact(G)
+		return opts.reduceCompacted(cg, cg.CompactPathSet(ps))
+	}
+	redG, ps, report := ReducePaths(G, ps, opts.Check)
+	if err := opts.superpathReport(report); err != nil {
+		return nil, nil, err
+	}
+	return redG.edges, ps, nil
+}
+
+// reduceCompacted performs x,y-detachments on a compacted graph until every read path is a single edge, see reduce
+func (opts *Options) reduceCompacted(cg *CompactedGraph, ps *PathSet) ([]*Edge, *PathSet, error) {
+	redCG, ps, report := ReduceCompactedPaths(cg, ps, opts.Check)
+	if err := opts.superpathReport(report); err != nil {
+		return nil, nil, err
+	}
+	return redCG.edges, ps, nil
+}
+
+// superpathReport returns the inconsistency a reduction stopped at, if any, and otherwise writes its report to
+// superpaths.txt, or to stderr without -out, if reads had to be cut or tangles are left
+func (opts *Options) superpathReport(report *SuperpathReport) error {
+	if report.Violation != nil {
+		return report.Violation
+	}
+	if report.Cuts > 0 || len(report.Tangles) > 0 {
+		return opts.writeLog("superpaths.txt", func(w io.Writer) {
+			WriteSuperpathReport(w, report)
+		})
+	}
+	return nil
+}
+
+// cleans returns true if any graph cleaning was requested
+func (opts *Options) cleans() bool {
+	return opts.TipLen > 0 || opts.BubbleLen > 0 || opts.ConnCount > 0 || opts.ConnRatio > 0
+}
+
+// clean removes tips, bubbles and erroneous connections from G and writes what was removed to cleaning.txt, or to stderr without -out
+// Read paths through a popped bubble are moved onto the branch that was kept, and the popped branches are written to
+// variants.txt
+func (opts *Options) clean(G *Graph, ps *PathSet) error {
+	var tips []*Tip
+	if opts.TipLen > 0 {
+		tips = G.ClipTips(opts.TipLen)
+	}
+	var bubbles []*Bubble
+	if opts.BubbleLen > 0 {
+		bubbles = G.PopBubbles(opts.BubbleLen)
+	}
+	for _, b := range bubbles {
+		ps.ReplaceSubpath(b.popped, b.kept)
+	}
+	conns := G.RemoveWeakConnections(opts.ConnCount, opts.ConnRatio)
+
+	if len(tips) > 0 || len(bubbles) > 0 || len(conns) > 0 {
+		err := opts.writeLog("cleaning.txt", func(w io.Writer) {
+			fmt.Fprintf(w, "Tips clipped:\t%d\n", len(tips))
+			fmt.Fprintf(w, "Bubbles popped:\t%d\n", len(bubbles))
+			fmt.Fprintf(w, "Erroneous connections removed:\t%d\n", len(conns))
+			for _, tip := range tips {
+				fmt.Fprintf(w, "Tip\t%s\tcov=%.2f\tjunction=%s\tbranch=%d\n", tip.Seq, tip.Coverage, tip.Junction, tip.Branch)
+			}
+			for _, c := range conns {
+				fmt.Fprintf(w, "Connection\t%s\tcov=%d\tsibling=%d\tnode=%s\tnode cov %d -> %d\n", c.Value, c.Weight, c.Sibling, c.Node, c.Before, c.After)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(bubbles) > 0 {
+		return opts.writeLog("variants.txt", func(w io.Writer) {
+			WriteVariants(w, bubbles)
+		})
+	}
+	return nil
+}
+
+// eulerianWalks returns the Eulerian walk of a graph as a contig, along with the edges it walks
+// If the graph has no Eulerian path it returns one walk for each path that EulerianPaths needs to cover the graph.
+// Each edge is walked as many times as its weight, so an l-tuple seen twice appears twice in the walk.
+func eulerianWalks(G *Graph, order TraversalOrder) ([]*Contig, []*Edge, *BalanceReport) {
+	balance := G.AnalyzeBalance()
+	var nodePaths [][]*Node
+	var edgePaths [][]*Edge
+	if balance.Eulerian {
+		P, E := GetEulerianPath(G, order)
+		nodePaths, edgePaths = [][]*Node{P}, [][]*Edge{E}
+	} else {
+		nodePaths, edgePaths = G.EulerianPaths(order)
+	}
+
+	var walks []*Contig
+	var walked []*Edge
+	for i := range nodePaths {
+		walked = append(walked, edgePaths[i]...)
+		walk := WalkContig(nodePaths[i], edgePaths[i], fmt.Sprintf("walk_%d", i+1))
+		walk.Info = "order=" + strings.ReplaceAll(order.String(), " ", ",")
+		walks = append(walks, walk)
+	}
+	return walks, walked, balance
+}
+
+// assembleCanonical writes the unitigs of the bidirected graph of the reads
+// An Eulerian walk is not defined on a bidirected graph, so the unitigs are the assembly
+func assembleCanonical(opts *Options, reads []string) error {
+	G := MakeBidirectedGraph(reads, opts.L)
+	unitigs := G.Unitigs()
+
+	if opts.hasFormat("txt") {
+		err := opts.writeOutput("assembly.txt", func(w io.Writer) error {
+			fmt.Fprintf(w, "Canonical graph: %d nodes, %d edges\n\n", G.NumNodes(), G.NumEdges())
+			for _, c := range unitigs {
+				fmt.Fprintf(w, "%s\t%s\t%.2f\n", c.ID, c.Seq, c.Coverage)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if opts.hasFormat("fasta") {
+		return opts.writeOutput("contigs.fasta", func(w io.Writer) error {
+			return WriteFasta(w, unitigs, opts.Wrap)
+		})
+	}
+	return nil
+}
+
+func runCountKmers(opts *Options) error {
+	reads, _, err := opts.loadReads()
+	if err != nil {
+		return err
+	}
+	lTupCounts := GenerateSamleLTuples(reads, opts.L, opts.Save)
+	if opts.Canonical {
+		lTupCounts = lTupCounts.Canonical()
+	}
+
+	return opts.writeOutput("ltuples.txt", func(w io.Writer) error {
+		return WriteLTupleCounts(w, lTupCounts)
+	})
+}
+
+func runGraph(opts *Options) error {
+	reads, _, err := opts.loadReads()
+	if err != nil {
+		return err
+	}
+	if opts.Canonical {
+		G := MakeBidirectedGraph(reads, opts.L)
+		return opts.writeOutput("graph.txt", func(w io.Writer) error {
+			for _, e := range G.edges {
+				fmt.Fprintf(w, "%s%s -> %s%s\t%s\t%d\n", e.start.value, strand(e.startRC), e.end.value, strand(e.endRC), e.value, e.weight)
+			}
+			return nil
+		})
+	}
+	G, _, fwPathSet := DebruinizeReads(reads, opts.L, opts.Save)
+
+	if opts.hasFormat("txt") {
+		err := opts.writeOutput("graph.txt", func(w io.Writer) error {
+			for _, e := range G.edges {
+				fmt.Fprintf(w, "%s -> %s\t%s\t%d\n", e.start.value, e.end.value, e.value, e.weight)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return opts.writeGraphDot(G, fwPathSet, nil)
+}
+
+// strand returns the orientation symbol of a node in a bidirected edge
+func strand(rc bool) string {
+	if rc {
+		return "-"
+	}
+	return "+"
+}
+
+func runReduce(opts *Options) error {
+	if opts.Canonical {
+		return errors.New("reduce does not support -canonical, read paths are only defined on the forward graph")
+	}
+	reads, _, err := opts.loadReads()
+	if err != nil {
+		return err
+	}
+	var redEdges []*Edge
+	var redFwPathSet *PathSet
+	if opts.Compact && !opts.cleans() && !opts.hasFormat("dot") {
+		// Nothing needs the Graph of every l-tuple, so the compacted graph and read paths are built from the counts
+		cg := CompactKmers(GenerateSamleLTuples(reads, opts.L, opts.Save))
+		redEdges, redFwPathSet, err = opts.reduceCompacted(cg, cg.CompactReads(reads))
+	} else {
+		G, _, fwPathSet := DebruinizeReads(reads, opts.L, opts.Save)
+		if err := opts.writeGraphDot(G, fwPathSet, nil); err != nil {
+			return err
+		}
+		redEdges, redFwPathSet, err = opts.reduce(G, fwPathSet)
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.hasFormat("txt") {
+		err := opts.writeOutput("reduced_paths.txt", func(w io.Writer) error {
+			writePathSet(w, "Reduced Read Path Set", redFwPathSet)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return opts.writeReduced(nil, redEdges, redFwPathSet)
+}
+
+func runStats(opts *Options) error {
+	reads, pairs, err := opts.loadReads()
+	if err != nil {
+		return err
+	}
+	if opts.Canonical {
+		G := MakeBidirectedGraph(reads, opts.L)
+		return opts.writeOutput("stats.txt", func(w io.Writer) error {
+			fmt.Fprintf(w, "Reads:\t%d\n", len(reads))
+			fmt.Fprintf(w, "l:\t%d\n", opts.L)
+			fmt.Fprintf(w, "Canonical nodes:\t%d\n", G.NumNodes())
+			fmt.Fprintf(w, "Canonical edges:\t%d\n", G.NumEdges())
+			return nil
+		})
+	}
+	// The l-tuples are counted once for both the graph and the compacted graph
+	lTupCounts := GenerateSamleLTuples(reads, opts.L, opts.Save)
+	G, fwPathSet := MakeDeBruijnGraph(lTupCounts), GenerateReadPathSet(reads, opts.L)
+	insertSize := EstimateInsertSize(pairs, opts.MinOverlap)
+	pairSet := GeneratePairSet(fwPathSet, len(reads)-2*len(pairs), pairs, opts.MinOverlap)
+
+	var bases int
+	for _, read := range reads {
+		bases += len(read)
+	}
+	balance := G.AnalyzeBalance()
+	cg := CompactKmers(lTupCounts)
+
+	return opts.writeOutput("stats.txt", func(w io.Writer) error {
+		fmt.Fprintf(w, "Reads:\t%d\n", len(reads))
+		fmt.Fprintf(w, "Bases:\t%d\n", bases)
+		fmt.Fprintf(w, "l:\t%d\n", opts.L)
+		fmt.Fprintf(w, "Nodes:\t%d\n", G.NumNodes())
+		fmt.Fprintf(w, "Edges:\t%d\n", G.NumEdges())
+		fmt.Fprintf(w, "Compacted nodes:\t%d\n", cg.NumNodes())
+		fmt.Fprintf(w, "Compacted edges:\t%d\n", cg.NumEdges())
+		fmt.Fprintf(w, "Unbalanced nodes:\t%d\n", len(balance.Unbalanced))
+		fmt.Fprintf(w, "Connected components:\t%d\n", len(balance.Components))
+		if balance.Eulerian {
+			fmt.Fprintf(w, "Eulerian path:\tyes\n")
+		} else {
+			fmt.Fprintf(w, "Eulerian path:\tno, %s\n", balance.Reason)
+		}
+		if len(*pairSet) > 0 {
+			fmt.Fprintf(w, "Mate pairs:\t%d\n", len(*pairSet))
+			fmt.Fprintf(w, "Overlapping mate pairs:\t%d\n", insertSize.Pairs)
+			if insertSize.Pairs > 0 {
+				fmt.Fprintf(w, "Insert size:\tmedian %d, mean %.1f, sd %.1f\n", insertSize.Median, insertSize.Mean, insertSize.StdDev)
+			} else {
+				fmt.Fprintf(w, "Insert size:\tunknown, no mates overlap\n")
+			}
+		}
+		return nil
+	})
+}
+
+func runStatsKmers(opts *Options) error {
+	reads, _, err := opts.loadReads()
+	if err != nil {
+		return err
+	}
+	var bases int
+	for _, read := range reads {
+		bases += len(read)
+	}
+	var readLen float64
+	if len(reads) > 0 {
+		readLen = float64(bases) / float64(len(reads))
+	}
+	hist := NewKmerHistogram(GenerateSamleLTuples(reads, opts.L, opts.Save))
+	est := hist.Estimate(opts.L, readLen)
+
+	return opts.writeOutput("kmers.txt", func(w io.Writer) error {
+		WriteSpectrum(w, hist, est)
+		return nil
+	})
+}
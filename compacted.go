@@ -0,0 +1,396 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// PathGraph is the set of graph operations ReducePaths needs
+// It is implemented by Graph, which has an edge per l-tuple, and CompactedGraph, which has an edge per unitig
+type PathGraph interface {
+	AddEdge(e *Edge)
+	RemoveEdge(e *Edge)
+	RemoveNode(n *Node)
+	GetEdgeFromUV(u, v string) *Edge
+	GetNodeFromValue(v string) *Node
+	FindEdge(u, v, value string) *Edge
+	EdgeByID(id int) *Edge
+	Edges() []*Edge
+	Degree(n *Node) (int, int)
+	Validate() error
+}
+
+// CompactedGraph is a de Bruijn graph in which each edge holds the sequence of a whole unitig
+// Its nodes are the (l-1)-mers where unitigs start and end, so it has far fewer nodes and edges than the Graph it was
+// built from. As in a Graph, several edges may join the same pair of nodes, e.g. the two branches of a bubble.
+type CompactedGraph struct {
+	nodes        []*Node
+	edges        []*Edge
+	nodeValueMap map[string]*Node
+	edgeValueMap map[string]map[string][]*Edge
+	inDegree     map[*Node]int
+	outDegree    map[*Node]int
+	edgeIDMap    map[int]*Edge
+	lastID       int // ID given to the last edge added
+}
+
+// NewCompactedGraph returns a CompactedGraph with initialized attributes
+func NewCompactedGraph() *CompactedGraph {
+	return &CompactedGraph{make([]*Node, 0), make([]*Edge, 0), make(map[string]*Node), make(map[string]map[string][]*Edge), make(map[*Node]int), make(map[*Node]int), make(map[int]*Edge), 0}
+}
+
+// pathSequence returns the sequence spelled by a list of consecutive edges
+func pathSequence(path []*Edge) string {
+	seq := path[0].value
+	for _, e := range path[1:] {
+		seq += e.value[len(e.start.value):]
+	}
+	return seq
+}
+
+// pathCoverage returns the mean weight of a list of edges
+func pathCoverage(path []*Edge) float64 {
+	var weights int
+	for _, e := range path {
+		weights += e.weight
+	}
+	return float64(weights) / float64(len(path))
+}
+
+// Compact returns a CompactedGraph with an edge for each unitig of g
+// The weight of a compacted edge is the mean weight of the edges it replaces, rounded to the nearest whole number
+func Compact(g *Graph) *CompactedGraph {
+	cg := NewCompactedGraph()
+	for _, path := range g.unitigPaths() {
+		u, v := &Node{path[0].start.value}, &Node{path[len(path)-1].end.value}
+		e := &Edge{start: u, end: v, value: pathSequence(path)}
+		cg.AddEdge(e)
+		e = cg.FindEdge(u.value, v.value, e.value)
+		for i := 1; i < int(math.Round(pathCoverage(path))); i++ {
+			cg.AddEdge(e)
+		}
+	}
+	return cg
+}
+
+// CompactPathSet returns the read paths of ps written in terms of the edges of the compacted graph
+// Each read path walks every compacted edge that holds one of its l-tuples. Reads that start or end part way along a
+// unitig are extended to the whole of its edge, since the unitig does not branch and so says no more than the read.
+func (cg *CompactedGraph) CompactPathSet(ps *PathSet) *PathSet {
+	idx := cg.unitigIndex()
+	compacted := make(PathSet, len(*ps))
+	for i, path := range *ps {
+		var lTups []string
+		for node := path.head; node != nil && node.next != nil; node = node.next {
+			if node.edge != nil {
+				lTups = append(lTups, node.edge.value)
+			}
+		}
+		compacted[i] = idx.compactPath(lTups)
+	}
+	return &compacted
+}
+
+// CompactReads returns the read paths of reads in terms of the edges of the compacted graph, see CompactPathSet
+// The l-tuple read paths are never built, so with CompactKmers the paths can be reduced without a Graph of every
+// l-tuple. As with GenerateReadPath, a read containing an ambiguous base gives the path of its longest run.
+func (cg *CompactedGraph) CompactReads(reads []string) *PathSet {
+	idx := cg.unitigIndex()
+	compacted := make(PathSet, len(reads))
+	for i, read := range reads {
+		if !isUpperACGT(read) {
+			read = longestRun(read)
+		}
+		var lTups []string
+		for j := 0; j <= len(read)-idx.k; j++ {
+			lTups = append(lTups, read[j:j+idx.k])
+		}
+		compacted[i] = idx.compactPath(lTups)
+	}
+	return &compacted
+}
+
+// unitigPos is the compacted edge an l-tuple lies on and the offset of the l-tuple along it
+type unitigPos struct {
+	edge   *Edge
+	offset int
+}
+
+// unitigIndex finds the compacted edge holding each l-tuple, with the l-tuples packed as in KmerCounter
+type unitigIndex struct {
+	k     int
+	short map[Kmer]unitigPos
+	long  map[string]unitigPos
+}
+
+// unitigIndex returns an index of the l-tuples of every edge of the graph
+func (cg *CompactedGraph) unitigIndex() *unitigIndex {
+	idx := &unitigIndex{short: make(map[Kmer]unitigPos), long: make(map[string]unitigPos)}
+	for _, e := range cg.edges {
+		idx.k = len(e.start.value) + 1
+		for i := 0; i <= len(e.value)-idx.k; i++ {
+			lTup := e.value[i : i+idx.k]
+			if idx.k <= MaxKmerLen {
+				km, _ := PackKmer(lTup)
+				idx.short[km] = unitigPos{e, i}
+			} else {
+				lk, _ := PackLongKmer(lTup)
+				idx.long[lk.Key()] = unitigPos{e, i}
+			}
+		}
+	}
+	return idx
+}
+
+// find returns the compacted edge an l-tuple lies on, and false if no edge holds it
+func (idx *unitigIndex) find(lTup string) (unitigPos, bool) {
+	if len(lTup) != idx.k {
+		return unitigPos{}, false
+	}
+	if idx.k <= MaxKmerLen {
+		km, ok := PackKmer(lTup)
+		pos, found := idx.short[km]
+		return pos, ok && found
+	}
+	lk, ok := PackLongKmer(lTup)
+	pos, found := idx.long[lk.Key()]
+	return pos, ok && found
+}
+
+// compactPath returns the read path through the compacted edges that hold a list of consecutive l-tuples
+// A new edge is entered wherever the next l-tuple is not the one after the last along the same edge. The path stops
+// at the first l-tuple no edge holds, and has no nodes if the first l-tuple is not held.
+func (idx *unitigIndex) compactPath(lTups []string) *ReadPath {
+	rp := &ReadPath{}
+	var last *PathNode
+	var prev unitigPos
+	for _, lTup := range lTups {
+		pos, ok := idx.find(lTup)
+		if !ok {
+			break
+		}
+		if last != nil && pos.edge == prev.edge && pos.offset == prev.offset+1 {
+			prev = pos
+			continue
+		}
+		if last == nil {
+			last = &PathNode{value: pos.edge.start.value}
+			rp.head, rp.len = last, 1
+		}
+		last.edge = &PathEdge{value: pos.edge.value}
+		last.next = &PathNode{value: pos.edge.end.value}
+		last = last.next
+		rp.len++
+		prev = pos
+	}
+	return rp
+}
+
+// CompactKmers returns a CompactedGraph with an edge for each unitig of the de Bruijn graph of the counted l-tuples
+// The graph is walked implicitly by looking up the l-tuples that extend each node, so the Graph of every l-tuple is
+// never built. The weight of a compacted edge is the mean count of its l-tuples, rounded to the nearest whole number.
+func CompactKmers(kc *KmerCounter) *CompactedGraph {
+	k := kc.K()
+	cg := NewCompactedGraph()
+	visited := NewKmerCounter(k)
+
+	// edgesOut returns the bases that extend an (l-1)-mer forwards, and internal reports whether it has one edge in and out
+	edgesOut := func(s string) []byte {
+		var bases []byte
+		for i := 0; i < len(codeBases); i++ {
+			if kc.Count(s+codeBases[i:i+1]) > 0 {
+				bases = append(bases, codeBases[i])
+			}
+		}
+		return bases
+	}
+	internal := func(s string) bool {
+		var in int
+		for i := 0; i < len(codeBases); i++ {
+			if kc.Count(codeBases[i:i+1]+s) > 0 {
+				in++
+			}
+		}
+		return in == 1 && len(edgesOut(s)) == 1
+	}
+
+	extend := func(lTup string) {
+		var sb strings.Builder
+		sb.WriteString(lTup)
+		weights, edges := kc.Count(lTup), 1
+		visited.AddRead(lTup)
+		for n := lTup[1:]; internal(n); {
+			next := n + string(edgesOut(n)[0])
+			if visited.Count(next) > 0 {
+				break
+			}
+			visited.AddRead(next)
+			sb.WriteByte(next[k-1])
+			weights += kc.Count(next)
+			edges++
+			n = next[1:]
+		}
+		seq := sb.String()
+		e := &Edge{start: &Node{seq[:k-1]}, end: &Node{seq[len(seq)-k+1:]}, value: seq}
+		cg.AddEdge(e)
+		e = cg.FindEdge(e.start.value, e.end.value, seq)
+		for i := 1; i < int(math.Round(float64(weights)/float64(edges))); i++ {
+			cg.AddEdge(e)
+		}
+	}
+
+	// Unitigs start at an l-tuple leaving a branching node, or a node with no edges in
+	kc.ForEach(func(lTup string, count int) {
+		if visited.Count(lTup) == 0 && !internal(lTup[:k-1]) {
+			extend(lTup)
+		}
+	})
+
+	// The remaining l-tuples form isolated cycles
+	var cycleTups []string
+	kc.ForEach(func(lTup string, count int) {
+		if visited.Count(lTup) == 0 {
+			cycleTups = append(cycleTups, lTup)
+		}
+	})
+	sort.Strings(cycleTups)
+	for _, lTup := range cycleTups {
+		if visited.Count(lTup) == 0 {
+			extend(lTup)
+		}
+	}
+	return cg
+}
+
+/*
+	CompactedGraph Methods
+*/
+
+// NumNodes returns the number of nodes in the graph
+func (cg *CompactedGraph) NumNodes() int {
+	return len(cg.nodes)
+}
+
+// NumEdges returns the number of edges in the graph
+func (cg *CompactedGraph) NumEdges() int {
+	return len(cg.edges)
+}
+
+// Degree returns the in and out degree of a node
+func (cg *CompactedGraph) Degree(n *Node) (int, int) {
+	return cg.inDegree[n], cg.outDegree[n]
+}
+
+// GetNodeFromValue returns the address of the node with a given value
+// If no node has given value returns nil
+func (cg *CompactedGraph) GetNodeFromValue(v string) *Node {
+	return cg.nodeValueMap[v]
+}
+
+// GetEdgesFromUV returns every edge from the node with value u to the node with value v
+func (cg *CompactedGraph) GetEdgesFromUV(u, v string) []*Edge {
+	return cg.edgeValueMap[u][v]
+}
+
+// GetEdgeFromUV returns a pointer to the first edge from a node with value u to a node with value v
+// Use FindEdge when there may be several edges between the nodes
+func (cg *CompactedGraph) GetEdgeFromUV(u, v string) *Edge {
+	if edges := cg.edgeValueMap[u][v]; len(edges) > 0 {
+		return edges[0]
+	}
+	return nil
+}
+
+// FindEdge returns the edge from the node with value u to the node with value v that has the given sequence
+func (cg *CompactedGraph) FindEdge(u, v, value string) *Edge {
+	for _, e := range cg.edgeValueMap[u][v] {
+		if e.value == value {
+			return e
+		}
+	}
+	return nil
+}
+
+// EdgeByID returns the edge with the given ID, or nil if it is not in the graph
+func (cg *CompactedGraph) EdgeByID(id int) *Edge {
+	return cg.edgeIDMap[id]
+}
+
+// Edges returns the edges of the graph
+func (cg *CompactedGraph) Edges() []*Edge {
+	return cg.edges
+}
+
+// AddNode adds a node to the graph.
+// If node is already in graph, function does nothing
+func (cg *CompactedGraph) AddNode(n *Node) {
+	if cg.GetNodeFromValue(n.value) == nil {
+		cg.nodes = append(cg.nodes, n)
+		cg.nodeValueMap[n.value] = n
+	}
+}
+
+// AddEdge adds an edge to the graph. Also adds start and end nodes to to graph if they were not already present.
+// If an edge with the same nodes and sequence is already in the graph the function increments its weight by 1
+func (cg *CompactedGraph) AddEdge(e *Edge) {
+	present := cg.FindEdge(e.start.value, e.end.value, e.value)
+	if present == nil {
+		cg.AddNode(e.start)
+		cg.AddNode(e.end)
+		e.weight = 1
+		cg.lastID++
+		e.id = cg.lastID
+		cg.edgeIDMap[e.id] = e
+		cg.edges = append(cg.edges, e)
+		if _, ok := cg.edgeValueMap[e.start.value]; !ok {
+			cg.edgeValueMap[e.start.value] = make(map[string][]*Edge)
+		}
+		cg.edgeValueMap[e.start.value][e.end.value] = append(cg.edgeValueMap[e.start.value][e.end.value], e)
+	} else {
+		present.weight++
+	}
+	cg.outDegree[cg.GetNodeFromValue(e.start.value)]++
+	cg.inDegree[cg.GetNodeFromValue(e.end.value)]++
+}
+
+// RemoveEdge will remove an edge from the graph.
+// If the edge has weight > 1 its weight is decremented by 1, otherwise it is removed from the graph
+// As with Graph, the weight and degrees are taken from the edge stored in the graph, which ends with weight 0 once removed
+func (cg *CompactedGraph) RemoveEdge(e *Edge) {
+	present := cg.FindEdge(e.start.value, e.end.value, e.value)
+	if present == nil {
+		return
+	}
+	if present.weight == 1 {
+		for i, edge := range cg.edges {
+			if edge == present {
+				cg.edges = append(cg.edges[:i], cg.edges[i+1:]...)
+				break
+			}
+		}
+		delete(cg.edgeIDMap, present.id)
+		unmapEdge(cg.edgeValueMap, present)
+	}
+	present.weight--
+	cg.outDegree[cg.GetNodeFromValue(present.start.value)]--
+	cg.inDegree[cg.GetNodeFromValue(present.end.value)]--
+}
+
+// RemoveNode removes a node with no edges from the graph
+func (cg *CompactedGraph) RemoveNode(n *Node) {
+	present := cg.GetNodeFromValue(n.value)
+	if present == nil || cg.inDegree[present] != 0 || cg.outDegree[present] != 0 {
+		return
+	}
+	for i, node := range cg.nodes {
+		if node == present {
+			cg.nodes = append(cg.nodes[:i], cg.nodes[i+1:]...)
+			break
+		}
+	}
+	delete(cg.nodeValueMap, n.value)
+	delete(cg.edgeValueMap, n.value)
+	delete(cg.inDegree, present)
+	delete(cg.outDegree, present)
+}
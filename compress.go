@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// Magic bytes at the start of compressed files
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ErrNoZstd is returned by OpenInput for zstd compressed input when the zstd command is not on the PATH
+var ErrNoZstd = errors.New("zstd input requires the zstd binary")
+
+// inputFile is a decompressed view of an input file that closes the file and any decompressor with it
+type inputFile struct {
+	io.Reader
+	closers []func() error
+}
+
+func (f *inputFile) Close() error {
+	var firstErr error
+	for i := len(f.closers) - 1; i >= 0; i-- {
+		if err := f.closers[i](); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// OpenInput opens a file for reading, decompressing it on the fly if it is gzip, bzip2 or zstd compressed
+// The compression is detected from the magic bytes at the start of the file rather than its extension
+// zstd is not in the standard library, so zstd input is streamed through the zstd command
+func OpenInput(filename string) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	in := &inputFile{closers: []func() error{file.Close}}
+
+	br := bufio.NewReader(file)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			in.Close()
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		in.Reader = gz
+		in.closers = append(in.closers, gz.Close)
+	case bytes.HasPrefix(magic, bzip2Magic):
+		in.Reader = bzip2.NewReader(br)
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := startZstd(br)
+		if err != nil {
+			in.Close()
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		in.Reader = zr
+		in.closers = append(in.closers, zr.Close)
+	default:
+		in.Reader = br
+	}
+	return in, nil
+}
+
+// zstdReader reads the output of a zstd process decompressing its input
+type zstdReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+// startZstd starts a zstd process decompressing r
+func startZstd(r io.Reader) (*zstdReader, error) {
+	path, err := exec.LookPath("zstd")
+	if err != nil {
+		return nil, ErrNoZstd
+	}
+	cmd := exec.Command(path, "-dc")
+	cmd.Stdin = r
+	cmd.Stderr = os.Stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &zstdReader{out, cmd}, nil
+}
+
+// Read returns the error from zstd once its output is exhausted, so corrupt input is not mistaken for the end of the file
+func (z *zstdReader) Read(p []byte) (int, error) {
+	n, err := z.ReadCloser.Read(p)
+	if err == io.EOF && z.cmd != nil {
+		cmd := z.cmd
+		z.cmd = nil
+		if waitErr := cmd.Wait(); waitErr != nil {
+			return n, fmt.Errorf("zstd: %w", waitErr)
+		}
+	}
+	return n, err
+}
+
+// Close stops the zstd process if it has not finished
+func (z *zstdReader) Close() error {
+	z.ReadCloser.Close()
+	if z.cmd != nil {
+		z.cmd.Process.Kill()
+		z.cmd.Wait()
+		z.cmd = nil
+	}
+	return nil
+}
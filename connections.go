@@ -0,0 +1,65 @@
+package main
+
+// Connection is an edge removed by RemoveWeakConnections
+type Connection struct {
+	Start, End string // Values of the nodes the edge joined
+	Value      string
+	Weight     int    // Weight of the removed edge
+	Sibling    int    // Weight of the heaviest other edge on the same side of the branching node
+	Node       string // Value of the branching node
+	Before     int    // Total weight of the edges on that side of the branching node before the removal
+	After      int    // Total weight of those edges after the removal
+}
+
+// RemoveWeakConnections removes low weight edges that leave or enter a branching node beside a much heavier edge
+// An edge is removed if its weight is at most maxCount and less than ratio times the weight of its heaviest sibling,
+// so the heaviest edge at a node is never removed.
+// A threshold of 0 is not applied, and nothing is removed if both are 0. The edges to remove are chosen before any
+// are removed, so the result does not depend on the order the nodes are visited. Returns the removed edges.
+func (g *Graph) RemoveWeakConnections(maxCount int, ratio float64) []*Connection {
+	if maxCount <= 0 && ratio <= 0 {
+		return nil
+	}
+	weak := func(e *Edge, sibling int) bool {
+		return e.weight < sibling && (maxCount <= 0 || e.weight <= maxCount) && (ratio <= 0 || float64(e.weight) < ratio*float64(sibling))
+	}
+
+	var removed []*Connection
+	seen := make(map[*Edge]bool)
+	for _, n := range g.nodes {
+		for _, side := range [][]*Edge{g.outEdges(n), g.inEdges(n)} {
+			if len(side) < 2 {
+				continue
+			}
+			total := 0
+			for _, e := range side {
+				total += e.weight
+			}
+			after := total
+			var conns []*Connection
+			for _, e := range side {
+				sibling := 0
+				for _, other := range side {
+					if other != e && other.weight > sibling {
+						sibling = other.weight
+					}
+				}
+				if seen[e] || !weak(e, sibling) {
+					continue
+				}
+				seen[e] = true
+				after -= e.weight
+				conns = append(conns, &Connection{e.start.value, e.end.value, e.value, e.weight, sibling, n.value, total, 0})
+			}
+			for _, conn := range conns {
+				conn.After = after
+			}
+			removed = append(removed, conns...)
+		}
+	}
+
+	for _, conn := range removed {
+		g.deletePath([]*Edge{g.GetEdgeFromUV(conn.Start, conn.End)})
+	}
+	return removed
+}
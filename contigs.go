@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Contig is an assembled sequence along with the mean weight of the edges it was built from
+type Contig struct {
+	ID       string
+	Seq      string
+	Coverage float64
+	Info     string // Extra metadata appended to the FASTA header
+}
+
+// WalkSequence returns the sequence spelled by a list of nodes returned by FindEulerianPath
+func WalkSequence(P []*Node) string {
+	if len(P) == 0 {
+		return ""
+	}
+	str := []string{P[0].value}
+	for _, n := range P[1:] {
+		str = append(str, string(n.value[len(n.value)-1]))
+	}
+	return strings.Join(str, "")
+}
+
+// WalkContig returns a contig for an Eulerian walk through the nodes P along the edges E
+// The coverage of the contig is the mean weight of the edges in the walk
+func WalkContig(P []*Node, E []*Edge, id string) *Contig {
+	c := &Contig{ID: id, Seq: WalkSequence(P)}
+	if len(E) > 0 {
+		var weights int
+		for _, e := range E {
+			weights += e.weight
+		}
+		c.Coverage = float64(weights) / float64(len(E))
+	}
+	return c
+}
+
+// EdgeContigs returns a contig for every edge in a list of graph edges
+// Contigs are sorted by decreasing length then sequence so the IDs are stable between runs
+func EdgeContigs(edges []*Edge, prefix string) []*Contig {
+	contigs := make([]*Contig, 0, len(edges))
+	for _, e := range edges {
+		contigs = append(contigs, &Contig{Seq: e.value, Coverage: float64(e.weight)})
+	}
+	SortContigs(contigs)
+	for i, c := range contigs {
+		c.ID = fmt.Sprintf("%s_%d", prefix, i+1)
+	}
+	return contigs
+}
+
+// SortContigs sorts contigs by decreasing length, breaking ties by sequence
+func SortContigs(contigs []*Contig) {
+	sort.Slice(contigs, func(i, j int) bool {
+		if len(contigs[i].Seq) != len(contigs[j].Seq) {
+			return len(contigs[i].Seq) > len(contigs[j].Seq)
+		}
+		return contigs[i].Seq < contigs[j].Seq
+	})
+}
+
+// WriteFasta writes contigs as FASTA records with the length and coverage in the header
+// Sequence lines are wrapped every width bases, or not at all if width <= 0
+func WriteFasta(w io.Writer, contigs []*Contig, width int) error {
+	writer := bufio.NewWriter(w)
+	for _, c := range contigs {
+		fmt.Fprintf(writer, ">%s len=%d cov=%.2f", c.ID, len(c.Seq), c.Coverage)
+		if c.Info != "" {
+			fmt.Fprint(writer, " ", c.Info)
+		}
+		fmt.Fprintln(writer)
+		if width <= 0 {
+			fmt.Fprintln(writer, c.Seq)
+			continue
+		}
+		for i := 0; i < len(c.Seq); i += width {
+			end := i + width
+			if end > len(c.Seq) {
+				end = len(c.Seq)
+			}
+			fmt.Fprintln(writer, c.Seq[i:end])
+		}
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// MaxCorrections is the most bases the spectral corrector will change in one read
+const MaxCorrections = 4
+
+// maxStartCorrections is the most bases changed in the first l-tuple of a read, since every way of changing them is tried
+const maxStartCorrections = 2
+
+// maxCorrectionStates bounds the number of partial corrections kept at each position of a read
+const maxCorrectionStates = 64
+
+// CorrectionReport counts the reads and bases changed by CorrectReads
+type CorrectionReport struct {
+	MinCount      int // Count at which an l-tuple is solid
+	Reads         int
+	Corrected     int // Reads with at least one base changed
+	Bases         int // Bases changed over all reads
+	Uncorrectable int // Reads with a weak l-tuple that no set of up to MaxCorrections substitutions made solid
+	Skipped       int // Reads shorter than l or containing a base other than A, C, G or T
+}
+
+// SpectralCorrector corrects reads against the spectrum of solid l-tuples
+// An l-tuple is solid if it was counted at least minCount times over the read set and weak otherwise
+type SpectralCorrector struct {
+	counts   *KmerCounter
+	minCount int
+}
+
+// correctionState is a partial correction of a read, ending with the (l-1)-mer it is stored under
+type correctionState struct {
+	prev  *correctionState
+	start string // Corrected first l-tuple of the read, set only on the first state
+	base  byte   // Base added by this state, unset on the first state
+	cost  int    // Bases changed so far
+}
+
+// NewSpectralCorrector returns a corrector for reads counted in counts
+// If minCount is 0 it is taken from the error trough of the count histogram, or 2 if the histogram has no trough
+func NewSpectralCorrector(counts *KmerCounter, minCount int) *SpectralCorrector {
+	if minCount <= 0 {
+		minCount = NewKmerHistogram(counts).Estimate(counts.K(), 0).ErrorTrough
+		if minCount < 2 {
+			minCount = 2
+		}
+	}
+	return &SpectralCorrector{counts, minCount}
+}
+
+// solid reports whether an l-tuple was counted at least minCount times
+func (sc *SpectralCorrector) solid(lTup string) bool {
+	return sc.counts.Count(lTup) >= sc.minCount
+}
+
+// Correct returns the read with the fewest base substitutions that makes every l-tuple in it solid
+// The substitutions are found by dynamic programming over the positions of the read. Each state is a corrected prefix
+// ending in a solid l-tuple, stored under its last l-1 bases so only the cheapest prefix ending in each (l-1)-mer is
+// kept. Returns false if the read has no such correction with up to MaxCorrections substitutions.
+func (sc *SpectralCorrector) Correct(read string) (string, int, bool) {
+	l := sc.counts.K()
+	if len(read) < l {
+		return read, 0, false
+	}
+	allSolid := true
+	for i := 0; i+l <= len(read) && allSolid; i++ {
+		allSolid = sc.solid(read[i : i+l])
+	}
+	if allSolid {
+		return read, 0, true
+	}
+
+	states := make(map[string]*correctionState)
+	var substitute func(lTup []byte, from, cost int)
+	substitute = func(lTup []byte, from, cost int) {
+		if sc.solid(string(lTup)) {
+			if best, ok := states[string(lTup[1:])]; !ok || cost < best.cost {
+				states[string(lTup[1:])] = &correctionState{start: string(lTup), cost: cost}
+			}
+		}
+		if cost == maxStartCorrections {
+			return
+		}
+		for i := from; i < l; i++ {
+			orig := lTup[i]
+			for j := 0; j < len(codeBases); j++ {
+				if int8(j) != baseCodes[orig] {
+					lTup[i] = codeBases[j]
+					substitute(lTup, i+1, cost+1)
+				}
+			}
+			lTup[i] = orig
+		}
+	}
+	substitute([]byte(read[:l]), 0, 0)
+
+	for i := l; i < len(read) && len(states) > 0; i++ {
+		next := make(map[string]*correctionState)
+		for _, suffix := range sortedStateKeys(states) {
+			state := states[suffix]
+			for j := 0; j < len(codeBases); j++ {
+				b := codeBases[j]
+				cost := state.cost
+				if baseCodes[b] != baseCodes[read[i]] {
+					cost++
+				}
+				if cost > MaxCorrections || !sc.solid(suffix+string(b)) {
+					continue
+				}
+				key := suffix[1:] + string(b)
+				if best, ok := next[key]; !ok || cost < best.cost {
+					next[key] = &correctionState{prev: state, base: b, cost: cost}
+				}
+			}
+		}
+		states = pruneStates(next)
+	}
+
+	var best *correctionState
+	for _, suffix := range sortedStateKeys(states) {
+		if best == nil || states[suffix].cost < best.cost {
+			best = states[suffix]
+		}
+	}
+	if best == nil {
+		return read, 0, false
+	}
+
+	// Follow the states back to the first l-tuple, filling in the read from the end
+	corrected := make([]byte, len(read))
+	state := best
+	for i := len(read) - 1; state.prev != nil; i-- {
+		corrected[i] = state.base
+		state = state.prev
+	}
+	copy(corrected, state.start)
+	return string(corrected), best.cost, true
+}
+
+// sortedStateKeys returns the (l-1)-mers of the states in sorted order, so ties are broken the same way every run
+func sortedStateKeys(states map[string]*correctionState) []string {
+	keys := make([]string, 0, len(states))
+	for key := range states {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pruneStates keeps the maxCorrectionStates cheapest states
+func pruneStates(states map[string]*correctionState) map[string]*correctionState {
+	if len(states) <= maxCorrectionStates {
+		return states
+	}
+	keys := sortedStateKeys(states)
+	sort.SliceStable(keys, func(i, j int) bool { return states[keys[i]].cost < states[keys[j]].cost })
+	pruned := make(map[string]*correctionState, maxCorrectionStates)
+	for _, key := range keys[:maxCorrectionStates] {
+		pruned[key] = states[key]
+	}
+	return pruned
+}
+
+// CorrectReads corrects every read against the l-tuples counted over all of them
+// Reads that cannot be corrected are returned unchanged. minCount is passed to NewSpectralCorrector.
+func CorrectReads(reads []string, l, minCount int) ([]string, *CorrectionReport) {
+	sc := NewSpectralCorrector(CountKmers(reads, l), minCount)
+	report := &CorrectionReport{MinCount: sc.minCount, Reads: len(reads)}
+	corrected := make([]string, len(reads))
+	for i, read := range reads {
+		corrected[i] = read
+		if len(read) < l || !isACGT(read) {
+			report.Skipped++
+			continue
+		}
+		seq, changed, ok := sc.Correct(read)
+		if !ok {
+			report.Uncorrectable++
+			continue
+		}
+		if changed > 0 {
+			corrected[i] = seq
+			report.Corrected++
+			report.Bases += changed
+		}
+	}
+	return corrected, report
+}
+
+// isACGT reports whether every base of a sequence is A, C, G or T
+func isACGT(seq string) bool {
+	for i := 0; i < len(seq); i++ {
+		if baseCodes[seq[i]] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteCorrectionReport writes the counts of a CorrectionReport
+func WriteCorrectionReport(w io.Writer, report *CorrectionReport) {
+	fmt.Fprintf(w, "Solid l-tuple count:\t%d\n", report.MinCount)
+	fmt.Fprintf(w, "Reads:\t%d\n", report.Reads)
+	fmt.Fprintf(w, "Reads corrected:\t%d\n", report.Corrected)
+	fmt.Fprintf(w, "Bases corrected:\t%d\n", report.Bases)
+	fmt.Fprintf(w, "Reads uncorrectable:\t%d\n", report.Uncorrectable)
+	fmt.Fprintf(w, "Reads skipped:\t%d\n", report.Skipped)
+}
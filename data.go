@@ -1,13 +1,16 @@
 package main
 
-//MakeDeBruijnGraph returns a de bruijn graph from a given list of l-tuples
-func MakeDeBruijnGraph(lTupMap []string) *Graph {
+//MakeDeBruijnGraph returns a de bruijn graph from a set of counted l-tuples
+// Each l-tuple becomes an edge whose weight is the number of times the l-tuple was counted
+func MakeDeBruijnGraph(lTupCounts *KmerCounter) *Graph {
 	deBruijn := NewGraph()
-	// For each l-tuple add an edge and nodes to a graph
-	for _, lTup := range lTupMap {
+	// For each l-tuple add an edge and nodes to a graph, once for each time it was counted
+	for _, lTup := range lTupCounts.LTuples() {
 		u, v := &Node{lTup[:len(lTup)-1]}, &Node{lTup[1:]}
 		e := &Edge{start: u, end: v, value: lTup}
-		deBruijn.AddEdge(e)
+		for i := lTupCounts.Count(lTup); i > 0; i-- {
+			deBruijn.AddEdge(e)
+		}
 	}
 	return deBruijn
 }
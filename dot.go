@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxDotLabel is the longest edge sequence written in full in a DOT label
+// Longer sequences, such as the edges of a reduced graph, keep their first and last bases around an ellipsis
+const maxDotLabel = 24
+
+// WriteDot writes edges and the nodes they join as a Graphviz digraph
+// Nodes are labeled by value. Each edge is labeled with its sequence, length and weight, and is drawn thicker and darker
+// the higher its weight is among the edges. Edges walked by highlight, such as an Eulerian walk or a read path, are
+// drawn in red. They are matched by their nodes and sequence, so highlight may come from another copy of the graph.
+func WriteDot(w io.Writer, name string, edges []*Edge, highlight []*Edge) error {
+	type key struct{ start, end, value string }
+	walked := make(map[key]int)
+	for _, e := range highlight {
+		walked[key{e.start.value, e.end.value, e.value}]++
+	}
+	minWeight, maxWeight := 0, 0
+	for i, e := range edges {
+		if i == 0 || e.weight < minWeight {
+			minWeight = e.weight
+		}
+		if e.weight > maxWeight {
+			maxWeight = e.weight
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", name)
+	fmt.Fprintln(&b, "    node [shape=ellipse]")
+	seen := make(map[string]bool)
+	for _, e := range edges {
+		for _, v := range []string{e.start.value, e.end.value} {
+			if !seen[v] {
+				seen[v] = true
+				fmt.Fprintf(&b, "    %q\n", v)
+			}
+		}
+	}
+	for _, e := range edges {
+		// Scale the weight to between 0 for the lightest edge and 1 for the heaviest
+		scale := 1.0
+		if maxWeight > minWeight {
+			scale = float64(e.weight-minWeight) / float64(maxWeight-minWeight)
+		}
+		label := fmt.Sprintf(`%s\nlen=%d w=%d`, dotSequence(e.value), len(e.value), e.weight)
+		color := fmt.Sprintf("gray%d", 70-int(60*scale))
+		if n := walked[key{e.start.value, e.end.value, e.value}]; n > 0 {
+			color = "red"
+			if n > 1 {
+				label += fmt.Sprintf(` x%d`, n)
+			}
+		}
+		fmt.Fprintf(&b, "    %q -> %q [label=\"%s\" penwidth=%.1f color=%s fontcolor=%s]\n",
+			e.start.value, e.end.value, label, 1+4*scale, color, color)
+	}
+	fmt.Fprintln(&b, "}")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotSequence returns the sequence shown in the label of an edge, shortened if it is longer than maxDotLabel
+func dotSequence(seq string) string {
+	if len(seq) <= maxDotLabel {
+		return seq
+	}
+	half := (maxDotLabel - 3) / 2
+	return seq[:half] + "..." + seq[len(seq)-half:]
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RecordReader reads SeqRecords one at a time, returning io.EOF after the last record
+type RecordReader interface {
+	Read() (*SeqRecord, error)
+}
+
+// FastaReader reads SeqRecords from a FASTA or multi-FASTA stream one at a time
+// Sequences may be wrapped over several lines. Records read from FASTA have no qualities.
+type FastaReader struct {
+	scanner *bufio.Scanner
+	line    int
+	header  string // Header of the next record, already read from the stream
+}
+
+// NewFastaReader returns a FastaReader reading from r
+func NewFastaReader(r io.Reader) *FastaReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLen)
+	return &FastaReader{scanner: scanner}
+}
+
+// Read returns the next record in the stream, or io.EOF once every record has been read
+func (fr *FastaReader) Read() (*SeqRecord, error) {
+	// Find the header of the record, skipping blank and comment lines
+	for fr.header == "" {
+		if !fr.scanner.Scan() {
+			if err := fr.scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		fr.line++
+		line := strings.TrimRight(fr.scanner.Text(), "\r")
+		if len(line) == 0 || line[0] == ';' {
+			continue
+		}
+		if line[0] != '>' {
+			return nil, &ParseError{fr.line, ErrMissingHeader, fmt.Sprintf("found %q", truncate(line, 20))}
+		}
+		fr.header = line
+	}
+	rec := &SeqRecord{ID: recordID(fr.header[1:])}
+	fr.header = ""
+
+	// Sequence lines continue until the next header or the end of the stream
+	var seq strings.Builder
+	for fr.scanner.Scan() {
+		fr.line++
+		line := strings.TrimRight(fr.scanner.Text(), "\r")
+		if len(line) > 0 && line[0] == '>' {
+			fr.header = line
+			break
+		}
+		if len(line) > 0 && line[0] == ';' {
+			continue
+		}
+		seq.WriteString(strings.TrimSpace(line))
+	}
+	if err := fr.scanner.Err(); err != nil {
+		return nil, err
+	}
+	rec.Seq = seq.String()
+
+	return rec, nil
+}
+
+// NewRecordReader returns a FASTA or FASTQ reader for r depending on its first non-blank character
+// Leading blank lines are left in the stream so line numbers in errors stay correct
+func NewRecordReader(r io.Reader) (RecordReader, error) {
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		b, err := br.Peek(n)
+		if len(b) < n {
+			if err == io.EOF {
+				// An empty stream has no records in either format
+				return NewFastqReader(br), nil
+			}
+			return nil, err
+		}
+		switch b[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '>', ';':
+			return NewFastaReader(br), nil
+		case '@':
+			return NewFastqReader(br), nil
+		}
+		return nil, &ParseError{1 + strings.Count(string(b), "\n"), ErrMissingHeader, fmt.Sprintf("input is neither FASTA nor FASTQ, starts with %q", b[n-1])}
+	}
+}
+
+// ReadAllRecords returns every remaining record from a RecordReader
+func ReadAllRecords(rr RecordReader) ([]*SeqRecord, error) {
+	var recs []*SeqRecord
+	for {
+		rec, err := rr.Read()
+		if err == io.EOF {
+			return recs, nil
+		} else if err != nil {
+			return recs, err
+		}
+		recs = append(recs, rec)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PhredOffset is the ASCII offset of the quality scores in a FASTQ file
+const PhredOffset = 33
+
+// maxLineLen is the longest line the readers will accept
+const maxLineLen = 64 * 1024 * 1024
+
+// Errors wrapped by ParseError describing what was wrong with a record
+var (
+	ErrMissingHeader   = errors.New("expected a header line")
+	ErrTruncatedRecord = errors.New("file ends in the middle of a record")
+	ErrQualityLength   = errors.New("quality and sequence lengths differ")
+	ErrInvalidQuality  = errors.New("invalid quality character")
+	ErrMismatchedID    = errors.New("separator ID does not match header")
+)
+
+// SeqRecord is a read with its ID, sequence and Phred quality scores
+type SeqRecord struct {
+	ID   string
+	Seq  string
+	Qual []byte // Phred scores for each base, nil if the input had no qualities
+}
+
+// ParseError is returned when a read file is malformed
+// Line is the 1-based line number the problem was found on
+type ParseError struct {
+	Line int
+	Err  error
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	if e.Msg == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v: %s", e.Line, e.Err, e.Msg)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// FastqReader reads SeqRecords from a FASTQ stream one at a time
+// Sequence and quality may be wrapped over several lines. The quality is read until it is as long as the sequence,
+// so quality lines starting with '@' or '+' are handled correctly.
+type FastqReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewFastqReader returns a FastqReader reading from r
+func NewFastqReader(r io.Reader) *FastqReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLen)
+	return &FastqReader{scanner: scanner}
+}
+
+// next advances to the next line, returning false at the end of the input
+func (fr *FastqReader) next() bool {
+	if fr.scanner.Scan() {
+		fr.line++
+		return true
+	}
+	return false
+}
+
+// eof returns the scanner's error if it stopped early, or io.EOF
+func (fr *FastqReader) eof() error {
+	if err := fr.scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// Read returns the next record in the stream, or io.EOF once every record has been read
+func (fr *FastqReader) Read() (*SeqRecord, error) {
+	// Skip blank lines between records
+	var header string
+	for {
+		if !fr.next() {
+			return nil, fr.eof()
+		}
+		header = strings.TrimRight(fr.scanner.Text(), "\r")
+		if len(header) > 0 {
+			break
+		}
+	}
+	if header[0] != '@' {
+		return nil, &ParseError{fr.line, ErrMissingHeader, fmt.Sprintf("found %q", truncate(header, 20))}
+	}
+	rec := &SeqRecord{ID: recordID(header[1:])}
+	headerLine := fr.line
+
+	// Sequence lines continue until the '+' separator
+	var seq strings.Builder
+	for {
+		if !fr.next() {
+			if err := fr.scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, &ParseError{fr.line, ErrTruncatedRecord, fmt.Sprintf("record %s starting on line %d has no quality", rec.ID, headerLine)}
+		}
+		line := strings.TrimRight(fr.scanner.Text(), "\r")
+		if len(line) > 0 && line[0] == '+' {
+			if id := recordID(line[1:]); id != "" && id != rec.ID {
+				return nil, &ParseError{fr.line, ErrMismatchedID, fmt.Sprintf("%q != %q", id, rec.ID)}
+			}
+			break
+		}
+		seq.WriteString(line)
+	}
+	rec.Seq = seq.String()
+
+	// Quality lines continue until the quality is as long as the sequence
+	rec.Qual = make([]byte, 0, len(rec.Seq))
+	for len(rec.Qual) < len(rec.Seq) {
+		if !fr.next() {
+			if err := fr.scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, &ParseError{fr.line, ErrTruncatedRecord, fmt.Sprintf("record %s has %d quality scores for %d bases", rec.ID, len(rec.Qual), len(rec.Seq))}
+		}
+		line := strings.TrimRight(fr.scanner.Text(), "\r")
+		for i := 0; i < len(line); i++ {
+			if line[i] < '!' || line[i] > '~' {
+				return nil, &ParseError{fr.line, ErrInvalidQuality, fmt.Sprintf("%q at column %d", line[i], i+1)}
+			}
+			rec.Qual = append(rec.Qual, line[i]-PhredOffset)
+		}
+	}
+	if len(rec.Qual) != len(rec.Seq) {
+		return nil, &ParseError{fr.line, ErrQualityLength, fmt.Sprintf("record %s has %d quality scores for %d bases", rec.ID, len(rec.Qual), len(rec.Seq))}
+	}
+
+	return rec, nil
+}
+
+// ReadAll returns every remaining record in the stream
+func (fr *FastqReader) ReadAll() ([]*SeqRecord, error) {
+	return ReadAllRecords(fr)
+}
+
+// recordID returns the ID from a header line without its leading '@', '>' or '+'
+// The ID is everything up to the first whitespace
+func recordID(header string) string {
+	fields := strings.Fields(header)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// truncate shortens s to at most n bytes for use in error messages
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// RecordSeqs returns the sequences of a list of records
+func RecordSeqs(recs []*SeqRecord) []string {
+	seqs := make([]string, len(recs))
+	for i, rec := range recs {
+		seqs[i] = rec.Seq
+	}
+	return seqs
+}
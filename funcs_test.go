@@ -1,7 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -71,8 +80,861 @@ func TestGenerateSampleLTuples(t *testing.T) {
 
 	v1 := GenerateSamleLTuples(t1reads, 3, "")
 
-	if !ListsEqual(v1, []string{"ACG", "CGC", "GCG", "CGT", "GTC", "TCG"}) {
-		t.Error("GenerateSampleLTuples(test1,3,false) =", v1)
+	if !ListsEqual(v1.LTuples(), []string{"ACG", "CGC", "GCG", "CGT", "GTC", "TCG"}) {
+		t.Error("GenerateSampleLTuples(test1,3,false) =", v1.LTuples())
+	}
+	counts := map[string]int{"ACG": 2, "CGC": 3, "GCG": 4, "CGT": 4, "GTC": 2, "TCG": 1}
+	for lTup, want := range counts {
+		if got := v1.Count(lTup); got != want {
+			t.Errorf("GenerateSampleLTuples count of %s = %d; wants %d", lTup, got, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	WriteLTupleCounts(&buf, v1)
+	if got := buf.String(); !strings.HasPrefix(got, "ACG\t2\nCGC\t3\n") {
+		t.Error("WriteLTupleCounts =", got)
+	}
+
+	G := MakeDeBruijnGraph(v1)
+	if e := G.GetEdgeFromUV("GC", "CG"); e == nil || e.weight != 4 || G.outDegree[G.GetNodeFromValue("GC")] != 4 {
+		t.Error("MakeDeBruijnGraph edge GCG =", e)
+	}
+	if e := G.Distinct().GetEdgeFromUV("GC", "CG"); e == nil || e.weight != 1 {
+		t.Error("Distinct edge GCG =", e)
+	}
+
+}
+
+func TestParseOptions(t *testing.T) {
+	cmd := commands()["graph"]
+
+	opts, err := parseOptions(cmd, []string{"-k", "3", "a.fastq", "-in", "b.fastq,c.fastq"})
+	if err != nil {
+		t.Fatal("parseOptions returned error:", err)
+	}
+	if !ListsEqual(opts.Inputs, []string{"a.fastq", "b.fastq", "c.fastq"}) {
+		t.Error("parseOptions inputs =", opts.Inputs)
+	}
+	if opts.L != 3 || !opts.hasFormat("txt") {
+		t.Errorf("parseOptions l = %d, formats = %v", opts.L, opts.Formats)
+	}
+
+	if _, err := parseOptions(cmd, []string{"a.fastq"}); err == nil {
+		t.Error("parseOptions without -k should fail")
+	}
+	if _, err := parseOptions(cmd, []string{"-k", "3", "-format", "png", "a.fastq"}); err == nil {
+		t.Error("parseOptions with unsupported format should fail")
+	}
+}
+
+func TestWriteFasta(t *testing.T) {
+	contigs := []*Contig{{ID: "contig_1", Seq: "ACGCGTCG", Coverage: 2}}
+
+	var b strings.Builder
+	if err := WriteFasta(&b, contigs, 3); err != nil {
+		t.Fatal(err)
+	}
+	want := ">contig_1 len=8 cov=2.00\nACG\nCGT\nCG\n"
+	if b.String() != want {
+		t.Errorf("WriteFasta wrote %q; wants %q", b.String(), want)
+	}
+}
+
+func TestFastqReader(t *testing.T) {
+	// Quality lines may start with '@' or '+'
+	fq := "@r1 desc\nACGT\n+\n@III\n@r2\nAC\nGT\n+r2\n+I\nII\n"
+	recs, err := NewFastqReader(strings.NewReader(fq)).ReadAll()
+	if err != nil {
+		t.Fatal("ReadAll returned error:", err)
+	}
+	if len(recs) != 2 || recs[0].ID != "r1" || recs[1].Seq != "ACGT" {
+		t.Fatalf("ReadAll = %+v", recs)
+	}
+	if !reflect.DeepEqual(recs[0].Qual, []byte{31, 40, 40, 40}) {
+		t.Error("ReadAll qualities =", recs[0].Qual)
+	}
+
+	_, err = NewFastqReader(strings.NewReader("@r1\nACGT\n+\nIIIII\n")).ReadAll()
+	var pe *ParseError
+	if !errors.As(err, &pe) || !errors.Is(err, ErrQualityLength) || pe.Line != 4 {
+		t.Error("ReadAll with long quality returned", err)
+	}
+
+	reads, _, err := ReadSequenceFile("small_test_2_qual.fastq")
+	if err != nil || !reflect.DeepEqual(reads, []string{"ATGGC", "GGCGTG", "GTGCA", "TGGCGT", "CGTGC"}) {
+		t.Errorf("ReadSequenceFile(small_test_2_qual.fastq) = %v, %v", reads, err)
+	}
+	// The original fixtures have no quality lines, so the next header is read as the quality of the first record
+	_, _, err = ReadSequenceFile("small_test.fastq")
+	if !errors.As(err, &pe) || !errors.Is(err, ErrQualityLength) || pe.Line != 4 {
+		t.Error("ReadSequenceFile(small_test.fastq) returned", err)
+	}
+}
+
+func TestNewRecordReader(t *testing.T) {
+	fa := "\n>s1 first\nACG\nCGT\n;comment\n>s2\nGCGTCG\n"
+	rr, err := NewRecordReader(strings.NewReader(fa))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rr.(*FastaReader); !ok {
+		t.Fatalf("NewRecordReader returned %T for FASTA input", rr)
+	}
+	recs, err := ReadAllRecords(rr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ListsEqual(RecordSeqs(recs), []string{"ACGCGT", "GCGTCG"}) || recs[0].ID != "s1" || recs[0].Qual != nil {
+		t.Errorf("ReadAllRecords = %+v", recs)
+	}
+
+	rr, err = NewRecordReader(strings.NewReader("@r1\nACGT\n+\nIIII\n"))
+	if _, ok := rr.(*FastqReader); err != nil || !ok {
+		t.Errorf("NewRecordReader returned %T, %v for FASTQ input", rr, err)
+	}
+}
+
+func TestOpenInputGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(">s1\nACGCGT\n"))
+	gz.Close()
+
+	filename := filepath.Join(t.TempDir(), "reads.fa")
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reads, _, err := ReadSequenceFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ListsEqual(reads, []string{"ACGCGT"}) {
+		t.Error("ReadSequenceFile on gzip input =", reads)
+	}
+}
+
+func TestOpenInputZstd(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "reads.fa.zst")
+	if err := os.WriteFile(filename, append(zstdMagic, 0, 0, 0, 0), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", "")
+	if _, _, err := ReadSequenceFile(filename); !errors.Is(err, ErrNoZstd) {
+		t.Error("ReadSequenceFile on zstd input without the zstd command returned", err)
+	}
+}
+
+func TestEstimateInsertSize(t *testing.T) {
+	// Fragment ACGTTGCAAGGCTTAC sequenced with 10 base mates
+	frag := "ACGTTGCAAGGCTTAC"
+	r1 := &SeqRecord{ID: "p1/1", Seq: frag[:10]}
+	r2 := &SeqRecord{ID: "p1/2", Seq: ReverseComplement(frag)[:10]}
+
+	pair, err := NewReadPair(r1, r2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	est := EstimateInsertSize([]*ReadPair{pair}, 4)
+	if est.Pairs != 1 || est.Median != len(frag) {
+		t.Errorf("EstimateInsertSize = %+v; wants median %d", est, len(frag))
+	}
+
+	// Mates that do not overlap have an unknown insert size even when the library has an estimate
+	apart, err := NewReadPair(&SeqRecord{ID: "p3/1", Seq: "AAAAAA"}, &SeqRecord{ID: "p3/2", Seq: "CCCCCC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs := []*ReadPair{pair, apart}
+	ps := GenerateReadPathSet(PairedReads(pairs), 3)
+	if pairSet := GeneratePairSet(ps, 0, pairs, 4); (*pairSet)[0].insert != len(frag) || (*pairSet)[1].insert != 0 {
+		t.Errorf("GeneratePairSet inserts = %d, %d", (*pairSet)[0].insert, (*pairSet)[1].insert)
+	}
+
+	// Splitting R1 at an N leaves a run too short to overlap R2, so the rebuilt pair has no insert size
+	masked := []*ReadPair{{ID: "p1", R1: &SeqRecord{ID: "p1/1", Seq: "ACGTTGNAAG"}, R2: r2}}
+	reads, _ := SplitReads(PairedReads(masked), 0, 3)
+	updated := UpdatePairs(masked, reads)
+	if updated[0].R1.Seq != "ACGTTG" || updated[0].R1.ID != "p1/1" || MateOverlapInsert(updated[0].R1.Seq, updated[0].R2.Seq, 4) != 0 {
+		t.Errorf("UpdatePairs = %+v", updated[0].R1)
+	}
+
+	if _, err := NewReadPair(r1, &SeqRecord{ID: "p2/2"}); !errors.Is(err, ErrMateMismatch) {
+		t.Error("NewReadPair with different IDs returned", err)
+	}
+}
+
+func TestBidirectedUnitigs(t *testing.T) {
+	// Reads from both strands of ATGGCGTGCA
+	reads := []string{"ATGGCGT", ReverseComplement("GCGTGCA")}
+	G := MakeBidirectedGraph(reads, 4)
+
+	unitigs := G.Unitigs()
+	want, _ := Canonical("ATGGCGTGCA")
+	if len(unitigs) != 1 || unitigs[0].Seq != want {
+		t.Fatalf("Unitigs() = %+v; wants one unitig %s", unitigs, want)
+	}
+	if e := G.GetEdge("CGTG"); e == nil || e != G.GetEdge("CACG") || e.weight != 1 {
+		t.Error("GetEdge(CGTG) =", e)
+	}
+}
+
+func TestFindEulerianPath(t *testing.T) {
+	// ACGCGTCG has the repeated edge CGC walked once and the 2-cycle CG -> GC -> CG
+	G := MakeDeBruijnGraph(CountKmers([]string{"ACGCGTCG"}, 3))
+	P, E := G.FindEulerianPath(G.FindStartNode(), TraversalOrder{})
+	if len(E) != G.NumEdges() || len(P) != len(E)+1 || P[0].value != "AC" {
+		t.Fatalf("FindEulerianPath walked %d nodes and %d edges", len(P), len(E))
+	}
+	for i, e := range E {
+		if e.start.value != P[i].value || e.end.value != P[i+1].value {
+			t.Errorf("edge %d %s does not join %s and %s", i, e.value, P[i].value, P[i+1].value)
+		}
+	}
+
+	// A cycle with many edges must not overflow the stack
+	big := NewGraph()
+	n := 200000
+	for i := 0; i < n; i++ {
+		u, v := &Node{strconv.Itoa(i)}, &Node{strconv.Itoa((i + 1) % n)}
+		big.AddEdge(&Edge{start: u, end: v, value: u.value + ">" + v.value})
+	}
+	P, E = big.FindEulerianPath(big.GetNodeFromValue("0"), TraversalOrder{})
+	if len(E) != n || len(P) != n+1 {
+		t.Errorf("FindEulerianPath on a cycle of %d edges walked %d edges", n, len(E))
+	}
+}
+
+func TestTraversalOrder(t *testing.T) {
+	G := MakeDeBruijnGraph(CountKmers([]string{"ACGCGTCG"}, 3))
+	P, _ := G.FindEulerianPath(G.FindStartNode(), TraversalOrder{})
+	if WalkSequence(P) != "ACGCGTCG" {
+		t.Error("lexicographic walk =", WalkSequence(P))
+	}
+
+	order := TraversalOrder{Random: true, Seed: 7}
+	first, _ := G.FindEulerianPath(G.FindStartNode(), order)
+	for i := 0; i < 5; i++ {
+		again, _ := G.FindEulerianPath(G.FindStartNode(), order)
+		if WalkSequence(again) != WalkSequence(first) {
+			t.Fatalf("walks with seed 7 differ: %s and %s", WalkSequence(first), WalkSequence(again))
+		}
+	}
+}
+
+func TestAnalyzeBalance(t *testing.T) {
+	// Two separate chains ACG and TTGCA
+	G := MakeDeBruijnGraph(CountKmers([]string{"ACGT", "TTGCA"}, 3))
+	report := G.AnalyzeBalance()
+	if report.Eulerian || len(report.Components) != 2 || len(report.Unbalanced) != 4 {
+		t.Errorf("AnalyzeBalance = %+v", report)
+	}
+	if G.FindStartNode() != nil {
+		t.Error("FindStartNode should be nil for a graph with 4 unbalanced nodes")
+	}
+
+	nodePaths, edgePaths := G.EulerianPaths(TraversalOrder{})
+	var seqs []string
+	for i, P := range nodePaths {
+		if len(P) != len(edgePaths[i])+1 {
+			t.Errorf("path %d has %d nodes and %d edges", i, len(P), len(edgePaths[i]))
+		}
+		seqs = append(seqs, WalkSequence(P))
+	}
+	if !ListsEqual(seqs, []string{"ACGT", "TTGCA"}) {
+		t.Error("EulerianPaths =", seqs)
+	}
+}
+
+func TestAssembleWalk(t *testing.T) {
+	// ACG is seen twice, so the walk has to use it twice
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "reads.fa")
+	if err := os.WriteFile(filename, []byte(">r1\nACGACGT\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"assemble", "-k", "3", "-out", dir, filename}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(filepath.Join(dir, "assembly.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "Eulerian Walk: ACGACGT\n") {
+		t.Errorf("assemble wrote %s", out)
+	}
+}
+
+func TestUnitigs(t *testing.T) {
+	G := MakeDeBruijnGraph(CountKmers([]string{"ACGCGTCG"}, 3))
+	var seqs []string
+	for _, c := range G.Unitigs() {
+		seqs = append(seqs, c.Seq)
+	}
+	if !ListsEqual(seqs, []string{"ACG", "CGCG", "CGTCG"}) {
+		t.Error("Unitigs() =", seqs)
+	}
+
+	// An isolated cycle is one unitig
+	cycle := MakeDeBruijnGraph(CountKmers([]string{"AACGTAA"}, 3))
+	if u := cycle.Unitigs(); len(u) != 1 || len(u[0].Seq) != 7 {
+		t.Errorf("Unitigs() of a cycle = %+v", u)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	G := MakeDeBruijnGraph(CountKmers([]string{"ACGCGTCG"}, 3))
+	cg := Compact(G)
+	if cg.NumNodes() != 2 || cg.NumEdges() != 3 {
+		t.Fatalf("Compact has %d nodes and %d edges; wants 2 and 3", cg.NumNodes(), cg.NumEdges())
+	}
+	// CGCG and CGTCG are parallel loops on CG
+	if len(cg.GetEdgesFromUV("CG", "CG")) != 2 || cg.FindEdge("CG", "CG", "CGTCG") == nil {
+		t.Error("GetEdgesFromUV(CG, CG) =", cg.GetEdgesFromUV("CG", "CG"))
+	}
+
+	ps := GenerateReadPathSet([]string{"ACGCGTCG"}, 3)
+	cps := cg.CompactPathSet(ps)
+	if got := ReadPathNodesString((*cps)[0]); got != "AC CG CG CG" {
+		t.Error("CompactPathSet nodes =", got)
+	}
+	if got := ReadPathSequence((*cps)[0]); got != "ACGCGTCG" {
+		t.Error("CompactPathSet sequence =", got)
+	}
+	// A read that starts and ends part way along unitigs walks the whole of each
+	if got := ReadPathSequence((*cg.CompactPathSet(GenerateReadPathSet([]string{"GCGTC"}, 3)))[0]); got != "CGCGTCG" {
+		t.Error("CompactPathSet sequence of a partial read =", got)
+	}
+	if got := ReadPathSequence(&ReadPath{}); got != "" {
+		t.Error("ReadPathSequence of an empty path =", got)
+	}
+
+	e := cg.FindEdge("AC", "CG", "ACG")
+	cg.RemoveEdge(e)
+	if cg.GetEdgeFromUV("AC", "CG") != nil || cg.outDegree[cg.GetNodeFromValue("AC")] != 0 {
+		t.Error("RemoveEdge left edge ACG in the graph")
+	}
+}
+
+func TestCompactedRemoveEdge(t *testing.T) {
+	cg := Compact(MakeDeBruijnGraph(CountKmers([]string{"ACGCGTCG", "ACGCGTCG"}, 3)))
+	stored := cg.FindEdge("CG", "CG", "CGTCG")
+
+	// A copy of an edge takes weight from the edge stored in the graph, and the other parallel edge is kept
+	copied := &Edge{start: &Node{"CG"}, end: &Node{"CG"}, value: "CGTCG"}
+	cg.RemoveEdge(copied)
+	cg.RemoveEdge(copied)
+	if stored.weight != 0 || cg.FindEdge("CG", "CG", "CGTCG") != nil || cg.EdgeByID(stored.id) != nil {
+		t.Errorf("RemoveEdge left %+v in the graph", stored)
+	}
+	if len(cg.GetEdgesFromUV("CG", "CG")) != 1 || cg.inDegree[cg.GetNodeFromValue("CG")] != 4 {
+		t.Errorf("RemoveEdge left edges %v and in degree %d on CG", cg.GetEdgesFromUV("CG", "CG"), cg.inDegree[cg.GetNodeFromValue("CG")])
+	}
+	if err := cg.Validate(); err != nil {
+		t.Error("Validate after RemoveEdge returned", err)
+	}
+}
+
+func TestCompactedReduce(t *testing.T) {
+	small, _, err := ReadSequenceFile("small_test_qual.fastq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contigs := func(edges []*Edge) []string {
+		var seqs []string
+		for _, e := range edges {
+			seqs = append(seqs, e.value)
+		}
+		sort.Strings(seqs)
+		return seqs
+	}
+	for _, reads := range [][]string{small, {"ACGCGTCG", "GTC"}} {
+		G, _, ps := DebruinizeReads(reads, 3, "")
+		G.SetInOutDegree()
+		redG, _, _ := ReducePaths(G, ps, true)
+		want := contigs(redG.edges)
+
+		// The compacted graph of the Graph, and the one built straight from the counts, give the same contigs
+		G, _, ps = DebruinizeReads(reads, 3, "")
+		cg := Compact(G)
+		redCG, _, report := ReduceCompactedPaths(cg, cg.CompactPathSet(ps), true)
+		if got := contigs(redCG.edges); !ListsEqual(got, want) || report.Violation != nil {
+			t.Errorf("ReduceCompactedPaths of %v = %v; wants %v", reads, got, want)
+		}
+		cg = CompactKmers(CountKmers(reads, 3))
+		redCG, _, _ = ReduceCompactedPaths(cg, cg.CompactReads(reads), true)
+		if got := contigs(redCG.edges); !ListsEqual(got, want) {
+			t.Errorf("ReduceCompactedPaths of CompactKmers of %v = %v; wants %v", reads, got, want)
+		}
+	}
+}
+
+func TestKmer(t *testing.T) {
+	for _, seq := range []string{"ACGTTGCA", "GATTACA", "ACGTACGTACGTACGTACGTACGTACGTACGT"} {
+		k := len(seq)
+		km, ok := PackKmer(seq)
+		if !ok || km.String(k) != seq {
+			t.Fatalf("PackKmer(%s) = %s, %v", seq, km.String(k), ok)
+		}
+		if got := km.ReverseComplement(k).String(k); got != ReverseComplement(seq) {
+			t.Errorf("ReverseComplement(%s) = %s", seq, got)
+		}
+		lk, _ := PackLongKmer(seq + seq)
+		if got := lk.ReverseComplement().String(); got != ReverseComplement(seq+seq) {
+			t.Errorf("LongKmer ReverseComplement(%s) = %s", seq+seq, got)
+		}
+		if got := lk.Append('G').String(); got != (seq + seq)[1:]+"G" {
+			t.Errorf("LongKmer Append(%s, G) = %s", seq+seq, got)
+		}
+	}
+
+	km, _ := PackKmer("GATTACA")
+	if got := km.Prefix(7).String(6); got != "GATTAC" {
+		t.Error("Prefix =", got)
+	}
+	if got := km.Suffix(7).String(6); got != "ATTACA" {
+		t.Error("Suffix =", got)
+	}
+	if got := km.Append('T', 7).String(7); got != "ATTACAT" {
+		t.Error("Append =", got)
+	}
+	if got := km.Prepend('C', 7).String(7); got != "CGATTAC" {
+		t.Error("Prepend =", got)
+	}
+	if c, rc := km.Canonical(7); c.String(7) != "GATTACA" || rc {
+		t.Errorf("Canonical = %s, %v", c.String(7), rc)
+	}
+	if _, ok := PackKmer("GANTACA"); ok {
+		t.Error("PackKmer accepted an N")
+	}
+
+	read := strings.Repeat("ACGCGTCG", 10)
+	for l, want := range map[int]int{3: 11, 40: 7} {
+		kc := CountKmers([]string{read, "NNN" + read[:l]}, l)
+		if got := kc.Count(read[:l]); got != want {
+			t.Errorf("l=%d Count(%s) = %d; wants %d", l, read[:l], got, want)
+		}
+		if !reflect.DeepEqual(kc.LTuples(), Keys(func() map[string]int {
+			m := make(map[string]int)
+			for _, lTup := range GenerateReadLTuples(read, l) {
+				m[lTup]++
+			}
+			return m
+		}())) {
+			t.Errorf("l=%d LTuples = %v", l, kc.LTuples())
+		}
+	}
+
+	// ACG and its reverse complement CGT are counted together, for short and long l-tuples alike
+	for _, l := range []int{3, 40} {
+		fw := strings.Repeat("ACG", 14)[:l]
+		kc := CountKmers([]string{fw, ReverseComplement(fw)}, l).Canonical()
+		if c, _ := Canonical(fw); kc.Len() != 1 || kc.Count(c) != 2 {
+			t.Errorf("l=%d Canonical counts %v with %s counted %d times", l, kc.LTuples(), c, kc.Count(c))
+		}
+	}
+
+	cg := CompactKmers(CountKmers([]string{"ACGCGTCG"}, 3))
+	if cg.NumNodes() != 2 || cg.NumEdges() != 3 || cg.FindEdge("CG", "CG", "CGTCG") == nil {
+		t.Errorf("CompactKmers has %d nodes and %d edges; wants 2 and 3", cg.NumNodes(), cg.NumEdges())
+	}
+}
+
+func TestKmerHistogram(t *testing.T) {
+	kc := CountKmers([]string{"ACGCGTCG", "ACGCGTCG"}, 3)
+	if hist := NewKmerHistogram(kc); !reflect.DeepEqual(hist, KmerHistogram{0, 0, 6}) {
+		t.Error("NewKmerHistogram =", hist)
+	}
+
+	// 1000 error l-tuples seen once and a genome of 2000 l-tuples with coverage 20
+	hist := make(KmerHistogram, 60)
+	hist[1], hist[2] = 1000, 30
+	for c := 3; c < len(hist); c++ {
+		hist[c] = int(math.Round(2000 * poisson(c, 20)))
+	}
+	est := hist.Estimate(21, 100)
+	// Poisson(20) has equal modes at 19 and 20
+	if est.ErrorTrough < 2 || est.ErrorTrough > 10 || est.HomCoverage < 19 || est.HomCoverage > 20 || est.HetCoverage != 0 {
+		t.Errorf("Estimate trough = %d, coverage = %d, het peak = %d", est.ErrorTrough, est.HomCoverage, est.HetCoverage)
+	}
+	if est.GenomeSize < 1900 || est.GenomeSize > 2150 {
+		t.Error("Estimate genome size =", est.GenomeSize)
+	}
+	if est.Heterozygosity > 0.001 || est.ErrorRate <= 0 || est.BaseCoverage != float64(est.HomCoverage)*1.25 {
+		t.Errorf("Estimate heterozygosity = %f, error rate = %f, base coverage = %f", est.Heterozygosity, est.ErrorRate, est.BaseCoverage)
+	}
+
+	if est := (KmerHistogram{0, 10, 2}).Estimate(21, 100); est.Peak != 0 {
+		t.Error("Estimate found a peak in errors only:", est)
+	}
+}
+
+func TestCorrectReads(t *testing.T) {
+	genome := "ATGGCGTGCAATCCGTTAGCAGGTCAT"
+	reads := []string{genome[:20], genome[4:24], genome[7:], genome[:20], genome[5:], genome[2:22]}
+	// One error in the middle of a read and one in its first l-tuple
+	withErrors := append(reads, genome[:9]+"T"+genome[10:20], "G"+genome[5:22])
+
+	corrected, report := CorrectReads(withErrors, 5, 2)
+	if corrected[6] != genome[:20] || corrected[7] != genome[4:22] {
+		t.Errorf("CorrectReads = %s, %s", corrected[6], corrected[7])
+	}
+	if report.Corrected != 2 || report.Bases != 2 || report.Uncorrectable != 0 || report.MinCount != 2 {
+		t.Errorf("CorrectReads report = %+v", report)
+	}
+	for i, read := range reads {
+		if corrected[i] != read {
+			t.Errorf("CorrectReads changed solid read %s to %s", read, corrected[i])
+		}
+	}
+
+	sc := NewSpectralCorrector(CountKmers(reads, 5), 2)
+	if _, _, ok := sc.Correct("TTTTTTTTTTTTTTTTTTTT"); ok {
+		t.Error("Correct corrected a read with no solid l-tuples")
+	}
+}
+
+func TestTrimRecords(t *testing.T) {
+	qual := func(s string) []byte {
+		q := []byte(s)
+		for i := range q {
+			q[i] -= PhredOffset
+		}
+		return q
+	}
+	recs := []*SeqRecord{
+		{"adapter", "ACGTACGTACAGATCGGAAGAGCACAC", nil},
+		{"partial", "ACGTACGTACGTAGATCGGA", nil},
+		{"lowqual", "ACGTACGTACGTTTTT", qual("IIIIIIIII5II####")},
+		{"short", "ACGT", nil},
+		{"ns", "ACNNNGTACGT", nil},
+	}
+	opts := TrimOptions{Window: 4, MinQual: 20, MinLen: 5, MaxN: 2, Adapters: []string{"AGATCGGAAGAGC"}}
+	kept, report := TrimRecords(recs, opts)
+
+	var seqs []string
+	for _, rec := range kept {
+		seqs = append(seqs, rec.Seq)
+	}
+	if !reflect.DeepEqual(seqs, []string{"ACGTACGTAC", "ACGTACGTACGT", "ACGTACGTACG"}) {
+		t.Error("TrimRecords kept", seqs)
+	}
+	if len(kept[2].Qual) != len(kept[2].Seq) {
+		t.Error("TrimRecords left qualities of length", len(kept[2].Qual))
+	}
+	want := TrimReport{Reads: 5, Kept: 3, AdapterClipped: 2, QualityTrimmed: 1, BasesRemoved: 30, TooShort: 1, TooManyN: 1}
+	if *report != want {
+		t.Errorf("TrimRecords report = %+v", *report)
+	}
+
+	pairs := []*ReadPair{{"p", recs[0], recs[3]}}
+	if kept, report := TrimPairs(pairs, opts); len(kept) != 0 || report.Orphaned != 1 {
+		t.Errorf("TrimPairs kept %d pairs, report %+v", len(kept), *report)
+	}
+}
+
+func TestAmbiguousBases(t *testing.T) {
+	if got := ReverseComplement("ACgtNRYkm-"); got != "NkmRYNacGT" {
+		t.Error("ReverseComplement =", got)
+	}
+	if got := SplitAmbiguous("acgTNNACGRt"); !reflect.DeepEqual(got, []string{"ACGT", "ACG", "T"}) {
+		t.Error("SplitAmbiguous =", got)
+	}
+	if got := GenerateReadLTuples("acgTNACGT", 3); !reflect.DeepEqual(got, []string{"ACG", "CGT", "ACG", "CGT"}) {
+		t.Error("GenerateReadLTuples =", got)
+	}
+	if got := ReadPathSequence(GenerateReadPath("ACNGTACGTa", 3)); got != "GTACGTA" {
+		t.Error("GenerateReadPath sequence =", got)
+	}
+
+	// The last four reads are mates, which keep their longest run, or nothing if it is shorter than l
+	reads := []string{"ACGTACGT", "acgtacgt", "ACGTNACGTAC", "ACNGT", "GGNGGGGG", "TTTTNTT", "ACGTAC", "TTNTTT"}
+	split, report := SplitReads(reads, 4, 4)
+	want := []string{"ACGTACGT", "ACGTACGT", "ACGT", "ACGTAC", "GGGGG", "TTTT", "ACGTAC", ""}
+	if !reflect.DeepEqual(split, want) {
+		t.Error("SplitReads =", split)
+	}
+	if *report != (AmbiguityReport{Reads: 8, SoftMasked: 1, Ambiguous: 5, AmbiguousBases: 5, Fragments: 2, Removed: 2, EmptyMates: 1}) {
+		t.Errorf("SplitReads report = %+v", *report)
+	}
+}
+
+func TestRemoveEdge(t *testing.T) {
+	G := MakeDeBruijnGraph(CountKmers([]string{"ACGT", "ACG"}, 3))
+	stored := G.GetEdgeFromUV("AC", "CG")
+
+	// A copy of an edge takes weight from the edge stored in the graph, not from the copy
+	copied := &Edge{start: &Node{"AC"}, end: &Node{"CG"}, value: "ACG", weight: 2}
+	G.RemoveEdge(copied)
+	if stored.weight != 1 || copied.weight != 2 || G.outDegree[G.GetNodeFromValue("AC")] != 1 {
+		t.Errorf("RemoveEdge weights = %d stored, %d copied", stored.weight, copied.weight)
+	}
+
+	// The last unit of weight removes the edge from the list and the maps
+	G.RemoveEdge(copied)
+	if _, ok := G.edgeValueMap["AC"]["CG"]; ok || G.EdgeByID(stored.id) != nil || len(G.edges) != 1 {
+		t.Errorf("RemoveEdge left %v in the graph", stored)
+	}
+	if err := G.Validate(); err != nil {
+		t.Error("Validate after RemoveEdge returned", err)
+	}
+
+	// DeleteEdge takes the whole weight at once
+	G = MakeDeBruijnGraph(CountKmers([]string{"ACGT", "ACGT", "ACG"}, 3))
+	stored = G.GetEdgeFromUV("AC", "CG")
+	G.DeleteEdge(stored)
+	if stored.weight != 0 || G.GetEdgeFromUV("AC", "CG") != nil || G.outDegree[G.GetNodeFromValue("AC")] != 0 || G.inDegree[G.GetNodeFromValue("CG")] != 0 {
+		t.Errorf("DeleteEdge left %v in the graph", stored)
+	}
+	if err := G.Validate(); err != nil {
+		t.Error("Validate after DeleteEdge returned", err)
+	}
+}
+
+func TestClipTips(t *testing.T) {
+	// Three reads of ACGTTGCA and one with an error at its end, which leaves the tip TTGG off node TTG
+	reads := []string{"ACGTTGCA", "ACGTTGCA", "ACGTTGCA", "ACGTTGG"}
+	G := MakeDeBruijnGraph(CountKmers(reads, 4))
+	ps := GenerateReadPathSet(reads, 4)
+
+	tips := G.ClipTips(8)
+	if len(tips) != 1 || tips[0].Seq != "TTGG" || tips[0].Junction != "TTG" || tips[0].Branch != 3 {
+		t.Fatalf("ClipTips = %+v", tips)
+	}
+	if G.GetEdgeFromUV("TTG", "TGG") != nil || G.GetNodeFromValue("TGG") != nil {
+		t.Error("ClipTips left the tip in the graph")
+	}
+	if _, ok := G.edgeValueMap["TTG"]["TGG"]; ok {
+		t.Error("RemoveEdge left an entry in the edge map")
+	}
+	if in, out := G.Degree(G.GetNodeFromValue("TTG")); in != 4 || out != 3 {
+		t.Errorf("Degree(TTG) = %d, %d; wants 4, 3", in, out)
 	}
 
+	ps.RemoveMissingEdges(G)
+	if got := ReadPathSequence((*ps)[3]); got != "ACGTTG" {
+		t.Error("RemoveMissingEdges left path", got)
+	}
+
+	// The ends of a path with no branch are not tips
+	if tips := MakeDeBruijnGraph(CountKmers([]string{"ACGTTGCA"}, 4)).ClipTips(8); len(tips) != 0 {
+		t.Error("ClipTips clipped", tips[0].Seq)
+	}
+}
+
+func TestPopBubbles(t *testing.T) {
+	// A SNP at the middle base: three reads carry C and one carries T
+	major, minor := "AAGTACGGA", "AAGTATGGA"
+	reads := []string{major, major, major, minor}
+	G := MakeDeBruijnGraph(CountKmers(reads, 4))
+	ps := GenerateReadPathSet(reads, 4)
+
+	bubbles := G.PopBubbles(10)
+	if len(bubbles) != 1 {
+		t.Fatalf("PopBubbles popped %d bubbles", len(bubbles))
+	}
+	b := bubbles[0]
+	if b.Start != "GTA" || b.End != "GGA" || b.Kept != "GTACGGA" || b.Popped != "GTATGGA" || b.KeptCoverage != 3 || b.PoppedCoverage != 1 {
+		t.Errorf("PopBubbles = %+v", *b)
+	}
+	if G.GetNodeFromValue("ATG") != nil || G.NumEdges() != 6 {
+		t.Errorf("PopBubbles left %d edges", G.NumEdges())
+	}
+	if in, out := G.Degree(G.GetNodeFromValue("GTA")); in != 4 || out != 3 {
+		t.Errorf("Degree(GTA) = %d, %d; wants 4, 3", in, out)
+	}
+
+	ps.ReplaceSubpath(b.popped, b.kept)
+	if got := ReadPathSequence((*ps)[3]); got != major || (*ps)[3].len != len(major)-2 {
+		t.Errorf("ReplaceSubpath moved the minor read to %s with length %d", got, (*ps)[3].len)
+	}
+
+	var buf bytes.Buffer
+	WriteVariants(&buf, bubbles)
+	if !strings.Contains(buf.String(), "GTA\tGGA\tGTACGGA\t3.00\tGTATGGA\t1.00") {
+		t.Error("WriteVariants =", buf.String())
+	}
+}
+
+func TestRemoveWeakConnections(t *testing.T) {
+	// ACGTTGCA is read 10 times and the chimera ACGTTTT once, joining TTG -> TTT beside TTG -> TGC
+	reads := []string{"ACGTTTT"}
+	for i := 0; i < 10; i++ {
+		reads = append(reads, "ACGTTGCA")
+	}
+	G := MakeDeBruijnGraph(CountKmers(reads, 4))
+	if conns := G.RemoveWeakConnections(0, 0); conns != nil {
+		t.Error("RemoveWeakConnections with no thresholds removed", len(conns))
+	}
+	if conns := G.RemoveWeakConnections(1, 0.05); len(conns) != 0 {
+		t.Error("RemoveWeakConnections removed an edge above the ratio:", conns[0].Value)
+	}
+
+	conns := G.RemoveWeakConnections(2, 0.2)
+	want := Connection{"GTT", "TTT", "GTTT", 1, 10, "GTT", 11, 10}
+	if len(conns) != 1 || *conns[0] != want {
+		t.Fatalf("RemoveWeakConnections = %+v", conns)
+	}
+	if G.GetEdgeFromUV("GTT", "TTT") != nil {
+		t.Error("RemoveWeakConnections left the chimeric edge in the graph")
+	}
+	if in, out := G.Degree(G.GetNodeFromValue("GTT")); in != 11 || out != 10 {
+		t.Errorf("Degree(GTT) = %d, %d; wants 11, 10", in, out)
+	}
+}
+
+func TestReducePaths(t *testing.T) {
+	// Each read spans the repeat ACGC, and the read of just the repeat is cut
+	reads := []string{"CACGCA", "GACGCT", "ACGC"}
+	G := MakeDeBruijnGraph(CountKmers(reads, 4))
+	G.SetInOutDegree()
+	redG, redPs, report := ReducePaths(G, GenerateReadPathSet(reads, 4), true)
+	var contigs []string
+	for _, e := range redG.edges {
+		contigs = append(contigs, e.value)
+	}
+	sort.Strings(contigs)
+	if !ListsEqual(contigs, []string{"CACGCA", "GACGCT"}) || report.Cuts != 1 || len(report.Tangles) != 0 {
+		t.Errorf("ReducePaths edges = %v, report = %+v", contigs, report)
+	}
+	if ReadPathSequence((*redPs)[0]) != "CACGCA" || (*redPs)[2].NumEdges() != 0 {
+		t.Error("ReducePaths paths =", ReadPathSequence((*redPs)[0]), ReadPathNodesString((*redPs)[2]))
+	}
+
+	// x and y give up the weight of every pair detached onto z, so no l-tuple of small_test_qual.fastq is left beside the
+	// contig, and its coverage is the mean count of its l-tuples, 16/6, rather than the number of reads
+	small, _, err := ReadSequenceFile("small_test_qual.fastq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	G, _, ps := DebruinizeReads(small, 3, "")
+	G.SetInOutDegree()
+	redG, _, _ = ReducePaths(G, ps, true)
+	if contigs := EdgeContigs(redG.edges, "contig"); len(contigs) != 1 || contigs[0].Seq != "ACGCGTCG" || contigs[0].Coverage != 3 {
+		t.Errorf("ReducePaths of small_test_qual.fastq = %+v", contigs)
+	}
+
+	// Both reads span TA to GA, so they are reduced to two parallel edges between the same nodes
+	reads = []string{"TACCGA", "TACGA"}
+	G = MakeDeBruijnGraph(CountKmers(reads, 3))
+	G.SetInOutDegree()
+	redG, redPs, report = ReducePaths(G, GenerateReadPathSet(reads, 3), true)
+	if len(report.Tangles) != 0 || (*redPs)[0].NumEdges() != 1 || (*redPs)[1].NumEdges() != 1 || len(redG.GetEdgesFromUV("TA", "GA")) != 2 {
+		t.Errorf("ReducePaths report = %+v, paths %s", report, ReadPathNodesString((*redPs)[0]))
+	}
+	cg := CompactKmers(CountKmers(reads, 3))
+	_, redPs, report = ReduceCompactedPaths(cg, cg.CompactReads(reads), true)
+	if len(report.Tangles) != 0 || (*redPs)[0].NumEdges() != 1 || (*redPs)[1].NumEdges() != 1 {
+		t.Errorf("ReduceCompactedPaths report = %+v", report)
+	}
+
+	// Read paths with no nodes have no edges to count, and an edge into an x,0-detached end node still enters the tangle
+	reads = []string{"CACGC", "GACGC", "ACGCA", "ACGCT"}
+	G = MakeDeBruijnGraph(CountKmers(reads, 5))
+	ps = GenerateReadPathSet(reads, 5)
+	ps.SetEdgeIDs(G)
+	(*ps)[1].head.next.value = "acgc"
+	*ps = append(*ps, &ReadPath{})
+	report = &SuperpathReport{}
+	report.findTangles(ps)
+	if len(report.Tangles) != 1 || report.Tangles[0].Node != "ACGC" || !ListsEqual(report.Tangles[0].In, []string{"CACGC", "GACGC"}) || !ListsEqual(report.Tangles[0].Out, []string{"ACGCA", "ACGCT"}) {
+		t.Errorf("findTangles = %+v", report.Tangles)
+	}
+
+	// No read spans the repeat, so it is left as a tangle. No read continues ACGC once the reads starting with it are
+	// cut, so it is detached from the repeat before CACG and GACG are joined onto it
+	reads = []string{"CACGC", "GACGC", "ACGCA", "ACGCT"}
+	G = MakeDeBruijnGraph(CountKmers(reads, 4))
+	G.SetInOutDegree()
+	redG, redPs, report = ReducePaths(G, GenerateReadPathSet(reads, 4), true)
+	if len(report.Tangles) != 1 || report.EmptyDetachments != 1 || report.Cuts != 2 || report.Violation != nil {
+		t.Fatalf("ReducePaths report = %+v", report)
+	}
+	tangle := report.Tangles[0]
+	if tangle.Node != "CGC" || !ListsEqual(tangle.In, []string{"CACGC", "GACGC"}) || !ListsEqual(tangle.Out, []string{"CGCA", "CGCT"}) || tangle.Cuts != 2 {
+		t.Errorf("ReducePaths tangle = %+v", tangle)
+	}
+	if ReadPathNodesString((*redPs)[0]) != "CAC cgc" || redG.GetEdgeFromUV("CAC", "cgc") == nil {
+		t.Error("ReducePaths x,0-detached path =", ReadPathNodesString((*redPs)[0]))
+	}
+}
+
+func TestDetachPath(t *testing.T) {
+	// The loop AAA is both x and y, and the read walks it three times
+	G := MakeDeBruijnGraph(CountKmers([]string{"AAAAA"}, 3))
+	ps := GenerateReadPathSet([]string{"AAAAA"}, 3)
+	ps.SetEdgeIDs(G)
+	x := G.GetEdgeFromUV("AA", "AA")
+	z := &Edge{start: x.start, end: x.end, value: "AAAA", id: x.id + 1}
+
+	// A third x is left over, and is not moved onto z since it could be followed by something other than y
+	path := (*ps)[0]
+	if moved := path.detach(x, x, z, false, false); moved != 1 {
+		t.Error("detach moved", moved)
+	}
+	nodes := 0
+	for node := path.head; node != nil; node = node.next {
+		nodes++
+	}
+	if path.NumEdges() != 2 || nodes != 3 || ReadPathSequence(path) != "AAAAA" {
+		t.Errorf("detach path = %s with len %d", ReadPathSequence(path), path.len)
+	}
+	if path.head.edge.id != z.id || path.IsEndEdge(x) == nil {
+		t.Error("detach edges =", path.head.edge, path.head.next.edge)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	G := MakeDeBruijnGraph(CountKmers([]string{"ACGCGTCG"}, 3))
+	if err := G.Validate(); err != nil {
+		t.Fatal("Validate on a new graph returned", err)
+	}
+
+	// Removing a node removes its edges with it
+	G.RemoveNode(G.GetNodeFromValue("GT"))
+	if err := G.Validate(); err != nil || G.GetEdgeFromUV("CG", "GT") != nil || G.GetNodeFromValue("GT") != nil {
+		t.Error("Validate after RemoveNode returned", err)
+	}
+
+	G.outDegree[G.GetNodeFromValue("AC")]++
+	var ie *InvariantError
+	if err := G.Validate(); !errors.Is(err, ErrDegree) || !errors.As(err, &ie) {
+		t.Error("Validate with a wrong degree returned", err)
+	}
+	_, _, report := ReducePaths(G, GenerateReadPathSet([]string{"ACGCG"}, 3), true)
+	if report.Violation == nil || report.Violation.Step != 0 || !errors.Is(report.Violation, ErrDegree) {
+		t.Error("ReducePaths on an inconsistent graph found", report.Violation)
+	}
+	G.outDegree[G.GetNodeFromValue("AC")]--
+
+	delete(G.edgeValueMap["AC"], "CG")
+	if err := G.Validate(); !errors.Is(err, ErrEdgeMap) {
+		t.Error("Validate with an edge missing from the map returned", err)
+	}
+}
+
+func TestWriteDot(t *testing.T) {
+	reads := []string{"ACGCGTCG", "ACGCG"}
+	G, _, ps := DebruinizeReads(reads, 3, "")
+	var b strings.Builder
+	if err := WriteDot(&b, "debruijn", G.edges, (*ps)[1].Edges()); err != nil {
+		t.Fatal(err)
+	}
+	dot := b.String()
+	for _, line := range []string{
+		`digraph "debruijn" {`,
+		`"AC"`,
+		`"AC" -> "CG" [label="ACG\nlen=3 w=2" penwidth=5.0 color=red fontcolor=red]`,
+		`"GT" -> "TC" [label="GTC\nlen=3 w=1" penwidth=1.0 color=gray70 fontcolor=gray70]`,
+	} {
+		if !strings.Contains(dot, line) {
+			t.Errorf("WriteDot output is missing %s:\n%s", line, dot)
+		}
+	}
+	if strings.Count(dot, " color=red") != 3 {
+		t.Errorf("WriteDot highlighted %d edges, want 3:\n%s", strings.Count(dot, " color=red"), dot)
+	}
+	if s := dotSequence(strings.Repeat("A", 30) + "C"); len(s) > maxDotLabel || !strings.HasSuffix(s, "...AAAAAAAAAC") {
+		t.Error("dotSequence =", s)
+	}
 }
@@ -1,16 +1,33 @@
 package main
 
 import (
+	"fmt"
 	"math/rand"
+	"sort"
 )
 
+// Graph is a de Bruijn graph with a node per (l-1)-mer and an edge per l-tuple, keyed by their sequences
+// The l-tuples are counted packed by KmerCounter, but a Graph holds them as strings, so it costs tens of bytes per
+// l-tuple. Large inputs should use the CompactedGraph that CompactKmers builds from the packed counts instead.
+// Edges with the same sequence are one edge with a weight, but edges with different sequences may join the same pair
+// of nodes, as the x,y-detachments of ReducePaths can make them.
 type Graph struct {
 	nodes        []*Node
 	edges        []*Edge
 	nodeValueMap map[string]*Node
-	edgeValueMap map[string]map[string]*Edge
+	edgeValueMap map[string]map[string][]*Edge
 	inDegree     map[*Node]int
 	outDegree    map[*Node]int
+	edgeIDMap    map[int]*Edge
+	lastID       int // ID given to the last edge added
+}
+
+// TraversalOrder chooses the order FindEulerianPath tries the edges leaving a node
+// By default edges are tried in lexicographic order of the child node values.
+// If Random is set the edges are shuffled with a source seeded by Seed, so the walk is random but reproducible
+type TraversalOrder struct {
+	Random bool
+	Seed   int64
 }
 
 type Node struct {
@@ -22,50 +39,124 @@ type Edge struct {
 	value      string
 	weight     int
 	traversed  int
+	id         int // Unique within the graph, set when the edge is added
 }
 
 // NewGraph returns a Graph with initialized attributes
 func NewGraph() *Graph {
-	return &Graph{make([]*Node, 0), make([]*Edge, 0), make(map[string]*Node), make(map[string]map[string]*Edge), make(map[*Node]int), make(map[*Node]int)}
+	return &Graph{make([]*Node, 0), make([]*Edge, 0), make(map[string]*Node), make(map[string]map[string][]*Edge), make(map[*Node]int), make(map[*Node]int), make(map[int]*Edge), 0}
 }
 
-func GetEulerianPath(g *Graph) []*Node {
+// GetEulerianPath returns the nodes and edges of an Eulerian path through the graph
+func GetEulerianPath(g *Graph, order TraversalOrder) ([]*Node, []*Edge) {
 	start := g.FindStartNode()
-	return g.FindEulerianPath(start, []*Node{})
+	return g.FindEulerianPath(start, order)
+}
+
+// String describes a traversal order for output metadata
+func (o TraversalOrder) String() string {
+	if o.Random {
+		return fmt.Sprintf("random seed=%d", o.Seed)
+	}
+	return "lexicographic"
 }
 
 //
 /* Graph Methods */
 //
 
-//FindEulerianPath Recursively builds and returns a list of nodes corresponding to the Eulerian path in the graph
-func (g *Graph) FindEulerianPath(n *Node, path []*Node) []*Node {
+// eulerStep is an entry on the stack used by FindEulerianPath, the node reached and the edge used to reach it
+type eulerStep struct {
+	node *Node
+	edge *Edge
+}
+
+// FindEulerianPath returns the nodes and edges of an Eulerian path starting at n, in the order they are walked
+// The path is built with Hierholzer's algorithm using an explicit stack, so it runs in time linear in the number of edges.
+// An edge with weight w is walked w times, and the traversed count of every edge is reset before the walk
+// The same graph and order always give the same path
+func (g *Graph) FindEulerianPath(n *Node, order TraversalOrder) ([]*Node, []*Edge) {
+	for _, e := range g.edges {
+		e.traversed = 0
+	}
+	return g.walkEdges(n, order, nil)
+}
+
+// walkEdges performs the walk for FindEulerianPath without resetting the traversed counts
+// extra lists edges that are not in the graph to walk as if they were, such as the virtual edges added by EulerianPaths
+func (g *Graph) walkEdges(n *Node, order TraversalOrder, extra map[*Node][]*Edge) ([]*Node, []*Edge) {
 	n = g.NodeInGraph(n)
-	g.inDegree[n]--
-	// How to handle multiple of the same edges
-	if g.outDegree[n] == 0 {
-		return append(path, n)
+	if n == nil {
+		return nil, nil
+	}
+	var rng *rand.Rand
+	if order.Random {
+		rng = rand.New(rand.NewSource(order.Seed))
 	}
 
-	childMap := g.edgeValueMap[n.value]
-	// Make list of children and random order to choose them in
-	childKeys, counter := make([]string, len(childMap)), 0
-	for i := range childMap {
-		childKeys[counter] = i
-		counter++
-	}
-	randOrder := rand.Perm(len(childMap))
-
-	for _, i := range randOrder {
-		k, v := childKeys[i], childMap[childKeys[i]]
-		c := g.GetNodeFromValue(k)
-		if g.outDegree[c] > 0 || g.inDegree[c] > 0 { // v.traversed < v.weight
-			v.traversed++
-			g.outDegree[n]--
-			path = g.FindEulerianPath(c, path)
+	children := make(map[*Node][]*Edge) // Edges leaving each node, listed the first time the node is reached
+	next := make(map[*Node]int)         // Index of the next edge to try for each node
+	stack := []eulerStep{{n, nil}}
+	var revNodes []*Node
+	var revEdges []*Edge
+
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		edges, ok := children[top.node]
+		if !ok {
+			edges = append(g.outEdges(top.node), extra[top.node]...)
+			if rng != nil {
+				rng.Shuffle(len(edges), func(i, j int) { edges[i], edges[j] = edges[j], edges[i] })
+			}
+			children[top.node] = edges
+		}
+
+		// Skip edges that have been walked as many times as their weight
+		i := next[top.node]
+		for i < len(edges) && edges[i].traversed >= edges[i].weight {
+			i++
 		}
+		next[top.node] = i
+
+		if i < len(edges) {
+			e := edges[i]
+			e.traversed++
+			stack = append(stack, eulerStep{g.GetNodeFromValue(e.end.value), e})
+		} else {
+			// Every edge out of the node has been walked, so it is the next node back from the end of the path
+			stack = stack[:len(stack)-1]
+			revNodes = append(revNodes, top.node)
+			if top.edge != nil {
+				revEdges = append(revEdges, top.edge)
+			}
+		}
+	}
+
+	// The nodes and edges were found from the end of the path to the start
+	nodes, edges := make([]*Node, len(revNodes)), make([]*Edge, len(revEdges))
+	for i, node := range revNodes {
+		nodes[len(revNodes)-1-i] = node
+	}
+	for i, e := range revEdges {
+		edges[len(revEdges)-1-i] = e
 	}
-	return append(path, n)
+	return nodes, edges
+}
+
+// outEdges returns the edges leaving a node sorted by the value of the node they end at
+func (g *Graph) outEdges(n *Node) []*Edge {
+	childMap := g.edgeValueMap[n.value]
+	edges := make([]*Edge, 0, len(childMap))
+	for _, parallel := range childMap {
+		edges = append(edges, parallel...)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].end.value != edges[j].end.value {
+			return edges[i].end.value < edges[j].end.value
+		}
+		return edges[i].value < edges[j].value
+	})
+	return edges
 }
 
 //SetInOutDegrees Sets the in and out degree for each node
@@ -109,8 +200,9 @@ func (g *Graph) FindStartNode() *Node {
 			}
 		}
 	} else if len(oddNodes) == 0 {
-		for k, v := range g.outDegree {
-			if v != 0 {
+		// Search the list of nodes rather than the degree map so the same node is chosen every time
+		for _, k := range g.nodes {
+			if g.outDegree[k] != 0 {
 				return k
 			}
 		}
@@ -129,6 +221,15 @@ func (g *Graph) NumEdges() int {
 	return len(g.edges)
 }
 
+// Distinct returns a copy of the graph in which every edge has weight 1
+func (g *Graph) Distinct() *Graph {
+	d := NewGraph()
+	for _, e := range g.edges {
+		d.AddEdge(&Edge{start: &Node{e.start.value}, end: &Node{e.end.value}, value: e.value})
+	}
+	return d
+}
+
 // NodeInGraph returns a pointer a node if the graph contains a node with the given value. Otherwise return nil
 func (g *Graph) NodeInGraph(n *Node) *Node {
 	if val, ok := g.nodeValueMap[n.value]; ok {
@@ -137,21 +238,50 @@ func (g *Graph) NodeInGraph(n *Node) *Node {
 	return nil
 }
 
-// EdgeInGraph returns true if the graph contains a edge with the given value
+// EdgeInGraph returns the edge stored in the graph with the same nodes and sequence as e, or nil if there is none
 func (g *Graph) EdgeInGraph(e *Edge) *Edge {
-	if val, ok := g.edgeValueMap[e.start.value]; ok { // If start node in edge start map
-		if val2, ok2 := val[e.end.value]; ok2 { // If end node in start node's end map
-			return val2
+	return g.FindEdge(e.start.value, e.end.value, e.value)
+}
+
+// FindEdge returns the edge from the node with value u to the node with value v that has the given sequence
+func (g *Graph) FindEdge(u, v, value string) *Edge {
+	for _, e := range g.edgeValueMap[u][v] {
+		if e.value == value {
+			return e
 		}
 	}
 	return nil
 }
 
-// GetEdgefromUV returns a pointer to the edge from a node with value u to a node with value v
-func (g *Graph) GetEdgeFromUV(u, v string) *Edge {
+// EdgeByID returns the edge with the given ID, or nil if it is not in the graph
+func (g *Graph) EdgeByID(id int) *Edge {
+	return g.edgeIDMap[id]
+}
+
+// Edges returns the edges of the graph
+func (g *Graph) Edges() []*Edge {
+	return g.edges
+}
+
+// Degree returns the in and out degree of a node
+func (g *Graph) Degree(n *Node) (int, int) {
+	return g.inDegree[n], g.outDegree[n]
+}
+
+// GetEdgesFromUV returns every edge from the node with value u to the node with value v
+func (g *Graph) GetEdgesFromUV(u, v string) []*Edge {
 	return g.edgeValueMap[u][v]
 }
 
+// GetEdgefromUV returns a pointer to the first edge from a node with value u to a node with value v
+// Use FindEdge when there may be several edges between the nodes
+func (g *Graph) GetEdgeFromUV(u, v string) *Edge {
+	if edges := g.edgeValueMap[u][v]; len(edges) > 0 {
+		return edges[0]
+	}
+	return nil
+}
+
 //GetNodeFromValue returns the address of the node with a given value
 // If no node has given value returns nil
 func (g *Graph) GetNodeFromValue(v string) *Node {
@@ -162,20 +292,21 @@ func (g *Graph) GetNodeFromValue(v string) *Node {
 }
 
 // AddEdge adds an edge to the graph. Also adds start and end nodes to to graph if they were not already present.
-// If edge is already in the graph the function increments the edge weight by 1
+// If an edge with the same nodes and sequence is already in the graph the function increments its weight by 1
 func (g *Graph) AddEdge(e *Edge) {
 	present := g.EdgeInGraph(e)
 	if present == nil { // If edge is not in the graph
 		g.AddNode(e.start)
 		g.AddNode(e.end)
 		e.weight = 1
+		g.lastID++
+		e.id = g.lastID
+		g.edgeIDMap[e.id] = e
 		g.edges = append(g.edges, e)
-		if endNodes, ok := g.edgeValueMap[e.start.value]; ok { // Start node is in edge map
-			// if start node is in edge map but the edge isn't present, the end node must not be in the end node map
-			endNodes[e.end.value] = e
-		} else { // neither start node nor end node are in either edge map
-			g.edgeValueMap[e.start.value] = map[string]*Edge{e.end.value: e}
+		if _, ok := g.edgeValueMap[e.start.value]; !ok {
+			g.edgeValueMap[e.start.value] = make(map[string][]*Edge)
 		}
+		g.edgeValueMap[e.start.value][e.end.value] = append(g.edgeValueMap[e.start.value][e.end.value], e)
 	} else { // If edge is already in graph
 		present.weight++
 	}
@@ -199,39 +330,85 @@ func (g *Graph) RemoveEdge(e *Edge) {
 	present := g.EdgeInGraph(e)
 	if present != nil {
 		if present.weight == 1 {
-			var newEdges []*Edge
-			for i, edge := range g.edges {
-				if edge == present { // remove edge from list of edges
-					newEdges = append(g.edges[:i], g.edges[i+1:]...)
-					break
-				}
-			}
-			g.edges = newEdges
-			g.edgeValueMap[e.start.value][e.end.value] = nil // remove edge from value map
-		} else if present.weight > 1 {
-			e.weight--
+			g.unlinkEdge(present)
 		}
+		present.weight--
 		// Decrease degrees of nodes connected to the edge
 		g.outDegree[g.GetNodeFromValue(e.start.value)]--
 		g.inDegree[g.GetNodeFromValue(e.end.value)]--
 	}
 }
 
-// RemoveNode removes a node from the graph
+// DeleteEdge removes an edge from the graph whatever its weight
+// The edge is unlinked once and its whole weight taken from the degrees of its nodes
+func (g *Graph) DeleteEdge(e *Edge) {
+	present := g.EdgeInGraph(e)
+	if present == nil {
+		return
+	}
+	g.unlinkEdge(present)
+	g.outDegree[g.GetNodeFromValue(e.start.value)] -= present.weight
+	g.inDegree[g.GetNodeFromValue(e.end.value)] -= present.weight
+	present.weight = 0
+}
+
+// unlinkEdge removes an edge stored in the graph from the list of edges and the maps, leaving its weight and the degrees
+func (g *Graph) unlinkEdge(present *Edge) {
+	for i, edge := range g.edges {
+		if edge == present { // remove edge from list of edges
+			g.edges = append(g.edges[:i], g.edges[i+1:]...)
+			break
+		}
+	}
+	unmapEdge(g.edgeValueMap, present)
+	delete(g.edgeIDMap, present.id)
+}
+
+// unmapEdge removes an edge from a map of the edges between each pair of nodes, deleting the entry once it is empty
+func unmapEdge(edgeValueMap map[string]map[string][]*Edge, present *Edge) {
+	parallel := edgeValueMap[present.start.value][present.end.value]
+	for i, edge := range parallel {
+		if edge == present {
+			parallel = append(parallel[:i], parallel[i+1:]...)
+			break
+		}
+	}
+	if len(parallel) == 0 {
+		delete(edgeValueMap[present.start.value], present.end.value)
+	} else {
+		edgeValueMap[present.start.value][present.end.value] = parallel
+	}
+}
+
+// RemoveNode removes a node from the graph along with the edges that start or end at it
 func (g *Graph) RemoveNode(n *Node) {
 	present := g.NodeInGraph(n)
-	var newNodes []*Node
-	if present != nil {
-		for i, node := range g.nodes {
-			if node == present { // remove node from list of nodes
-				newNodes = append(g.nodes[:i], g.nodes[i+1:]...)
-			}
-		}
+	if present == nil {
+		return
+	}
 
-		// Remove edges that stat and end at this node
+	// Remove edges that start and end at this node
+	for _, e := range g.outEdges(present) {
+		g.DeleteEdge(e)
+	}
+	var in []*Edge
+	for _, e := range g.edges {
+		if e.end.value == n.value {
+			in = append(in, e)
+		}
+	}
+	for _, e := range in {
+		g.DeleteEdge(e)
+	}
 
-		// Remove node from node value map and set now list of nodes
-		g.nodeValueMap[n.value] = nil
-		g.nodes = newNodes
+	for i, node := range g.nodes {
+		if node == present { // remove node from list of nodes
+			g.nodes = append(g.nodes[:i], g.nodes[i+1:]...)
+			break
+		}
 	}
+	delete(g.nodeValueMap, n.value)
+	delete(g.edgeValueMap, n.value)
+	delete(g.inDegree, present)
+	delete(g.outDegree, present)
 }
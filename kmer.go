@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// MaxKmerLen is the longest k-mer that fits in a Kmer
+const MaxKmerLen = 32
+
+// Kmer is a k-mer of at most 32 bases packed 2 bits per base, with the first base in the highest used bits
+// A Kmer does not store its length, so methods that need it take k
+type Kmer uint64
+
+// LongKmer is a k-mer of any length packed 2 bits per base into as many words as it needs
+// Base i is stored in word i/32 with the first base of each word in its highest bits
+type LongKmer struct {
+	words []uint64
+	k     int
+}
+
+// baseCodes maps each base to its 2 bit code. Complementary bases have codes that sum to 3
+var baseCodes = [256]int8{}
+
+// codeBases maps each 2 bit code back to its base
+const codeBases = "ACGT"
+
+func init() {
+	for i := range baseCodes {
+		baseCodes[i] = -1
+	}
+	for code, b := range codeBases {
+		baseCodes[b] = int8(code)
+		baseCodes[b+'a'-'A'] = int8(code)
+	}
+}
+
+// kmerMask returns a mask covering the bits of a k-mer of length k
+func kmerMask(k int) Kmer {
+	if k >= MaxKmerLen {
+		return ^Kmer(0)
+	}
+	return Kmer(1)<<(2*uint(k)) - 1
+}
+
+// PackKmer packs a sequence of at most 32 bases into a Kmer
+// Returns false if the sequence is too long or contains a base other than A, C, G or T
+func PackKmer(s string) (Kmer, bool) {
+	if len(s) > MaxKmerLen {
+		return 0, false
+	}
+	var km Kmer
+	for i := 0; i < len(s); i++ {
+		code := baseCodes[s[i]]
+		if code < 0 {
+			return 0, false
+		}
+		km = km<<2 | Kmer(code)
+	}
+	return km, true
+}
+
+// String returns the sequence of a Kmer of length k
+func (km Kmer) String(k int) string {
+	b := make([]byte, k)
+	for i := k - 1; i >= 0; i-- {
+		b[i] = codeBases[km&3]
+		km >>= 2
+	}
+	return string(b)
+}
+
+// ReverseComplement returns the reverse complement of a Kmer of length k
+func (km Kmer) ReverseComplement(k int) Kmer {
+	// Complement every base, then reverse the order of the 2 bit codes
+	x := uint64(^km)
+	x = (x>>2)&0x3333333333333333 | (x&0x3333333333333333)<<2
+	x = (x>>4)&0x0F0F0F0F0F0F0F0F | (x&0x0F0F0F0F0F0F0F0F)<<4
+	x = bits.ReverseBytes64(x)
+	return Kmer(x >> (64 - 2*uint(k)))
+}
+
+// Canonical returns the smaller of a Kmer of length k and its reverse complement, and true if that is the reverse complement
+func (km Kmer) Canonical(k int) (Kmer, bool) {
+	rc := km.ReverseComplement(k)
+	if rc < km {
+		return rc, true
+	}
+	return km, false
+}
+
+// Prefix returns the first k-1 bases of a Kmer of length k
+func (km Kmer) Prefix(k int) Kmer {
+	return km >> 2
+}
+
+// Suffix returns the last k-1 bases of a Kmer of length k
+func (km Kmer) Suffix(k int) Kmer {
+	return km & kmerMask(k-1)
+}
+
+// Append returns the Kmer of length k made by dropping the first base and adding base b to the end
+func (km Kmer) Append(b byte, k int) Kmer {
+	return (km<<2 | Kmer(baseCodes[b]&3)) & kmerMask(k)
+}
+
+// Prepend returns the Kmer of length k made by dropping the last base and adding base b to the start
+func (km Kmer) Prepend(b byte, k int) Kmer {
+	return km>>2 | Kmer(baseCodes[b]&3)<<(2*uint(k-1))
+}
+
+/*
+	LongKmer Methods
+*/
+
+// newLongKmer returns a LongKmer of length k with every base A
+func newLongKmer(k int) LongKmer {
+	return LongKmer{make([]uint64, (k+MaxKmerLen-1)/MaxKmerLen), k}
+}
+
+// PackLongKmer packs a sequence of any length into a LongKmer
+// Returns false if the sequence contains a base other than A, C, G or T
+func PackLongKmer(s string) (LongKmer, bool) {
+	lk := newLongKmer(len(s))
+	for i := 0; i < len(s); i++ {
+		code := baseCodes[s[i]]
+		if code < 0 {
+			return LongKmer{}, false
+		}
+		lk.set(i, uint64(code))
+	}
+	return lk, true
+}
+
+// get returns the code of base i
+func (lk LongKmer) get(i int) uint64 {
+	return lk.words[i/MaxKmerLen] >> (62 - 2*uint(i%MaxKmerLen)) & 3
+}
+
+// set sets base i to code, which must be an A in lk
+func (lk LongKmer) set(i int, code uint64) {
+	lk.words[i/MaxKmerLen] |= code << (62 - 2*uint(i%MaxKmerLen))
+}
+
+// Len returns the number of bases in the k-mer
+func (lk LongKmer) Len() int {
+	return lk.k
+}
+
+// String returns the sequence of the k-mer
+func (lk LongKmer) String() string {
+	b := make([]byte, lk.k)
+	for i := range b {
+		b[i] = codeBases[lk.get(i)]
+	}
+	return string(b)
+}
+
+// Key returns the packed words of the k-mer as a string, for use as a map key
+// Keys of k-mers with the same length sort in the same order as their sequences
+func (lk LongKmer) Key() string {
+	var sb strings.Builder
+	sb.Grow(8 * len(lk.words))
+	var buf [8]byte
+	for _, w := range lk.words {
+		binary.BigEndian.PutUint64(buf[:], w)
+		sb.Write(buf[:])
+	}
+	return sb.String()
+}
+
+// ReverseComplement returns the reverse complement of the k-mer
+func (lk LongKmer) ReverseComplement() LongKmer {
+	rc := newLongKmer(lk.k)
+	for i := 0; i < lk.k; i++ {
+		rc.set(lk.k-1-i, 3-lk.get(i))
+	}
+	return rc
+}
+
+// Canonical returns the smaller of the k-mer and its reverse complement, and true if that is the reverse complement
+func (lk LongKmer) Canonical() (LongKmer, bool) {
+	rc := lk.ReverseComplement()
+	if rc.Key() < lk.Key() {
+		return rc, true
+	}
+	return lk, false
+}
+
+// slice returns the bases from i to j as a new LongKmer
+func (lk LongKmer) slice(i, j int) LongKmer {
+	s := newLongKmer(j - i)
+	for n := i; n < j; n++ {
+		s.set(n-i, lk.get(n))
+	}
+	return s
+}
+
+// Prefix returns the first k-1 bases of the k-mer
+func (lk LongKmer) Prefix() LongKmer {
+	return lk.slice(0, lk.k-1)
+}
+
+// Suffix returns the last k-1 bases of the k-mer
+func (lk LongKmer) Suffix() LongKmer {
+	return lk.slice(1, lk.k)
+}
+
+// Append returns the k-mer made by dropping the first base and adding base b to the end
+func (lk LongKmer) Append(b byte) LongKmer {
+	next := newLongKmer(lk.k)
+	for i := 1; i < lk.k; i++ {
+		next.set(i-1, lk.get(i))
+	}
+	next.set(lk.k-1, uint64(baseCodes[b]&3))
+	return next
+}
+
+// Prepend returns the k-mer made by dropping the last base and adding base b to the start
+func (lk LongKmer) Prepend(b byte) LongKmer {
+	next := newLongKmer(lk.k)
+	next.set(0, uint64(baseCodes[b]&3))
+	for i := 0; i < lk.k-1; i++ {
+		next.set(i+1, lk.get(i))
+	}
+	return next
+}
+
+/*
+	K-mer counting
+*/
+
+// KmerCounter counts the l-tuples in a set of reads, storing each one packed 2 bits per base
+// l-tuples of up to 32 bases are stored as a Kmer, longer ones by the Key of their LongKmer.
+// l-tuples containing a base other than A, C, G or T are not counted.
+type KmerCounter struct {
+	k     int
+	short map[Kmer]uint32
+	long  map[string]uint32
+}
+
+// NewKmerCounter returns a KmerCounter for l-tuples of length k
+func NewKmerCounter(k int) *KmerCounter {
+	kc := &KmerCounter{k: k}
+	if k <= MaxKmerLen {
+		kc.short = make(map[Kmer]uint32)
+	} else {
+		kc.long = make(map[string]uint32)
+	}
+	return kc
+}
+
+// K returns the length of the counted l-tuples
+func (kc *KmerCounter) K() int {
+	return kc.k
+}
+
+// AddRead counts every l-tuple in a read
+func (kc *KmerCounter) AddRead(read string) {
+	if kc.short == nil {
+		for i := 0; i <= len(read)-kc.k; i++ {
+			if lk, ok := PackLongKmer(read[i : i+kc.k]); ok {
+				kc.long[lk.Key()]++
+			}
+		}
+		return
+	}
+
+	// Roll a packed l-tuple along the read, starting again after any base that is not A, C, G or T
+	var km Kmer
+	valid := 0
+	for i := 0; i < len(read); i++ {
+		if baseCodes[read[i]] < 0 {
+			valid = 0
+			continue
+		}
+		km = km.Append(read[i], kc.k)
+		if valid++; valid >= kc.k {
+			kc.short[km]++
+		}
+	}
+}
+
+// Count returns the number of times an l-tuple was counted
+func (kc *KmerCounter) Count(lTup string) int {
+	if kc.short != nil {
+		if km, ok := PackKmer(lTup); ok && len(lTup) == kc.k {
+			return int(kc.short[km])
+		}
+		return 0
+	}
+	if lk, ok := PackLongKmer(lTup); ok && len(lTup) == kc.k {
+		return int(kc.long[lk.Key()])
+	}
+	return 0
+}
+
+// Len returns the number of distinct l-tuples counted
+func (kc *KmerCounter) Len() int {
+	if kc.short != nil {
+		return len(kc.short)
+	}
+	return len(kc.long)
+}
+
+// ForEach calls f with every distinct l-tuple and its count
+func (kc *KmerCounter) ForEach(f func(lTup string, count int)) {
+	if kc.short != nil {
+		for km, count := range kc.short {
+			f(km.String(kc.k), int(count))
+		}
+		return
+	}
+	for key, count := range kc.long {
+		lk := LongKmer{make([]uint64, len(key)/8), kc.k}
+		for i := range lk.words {
+			lk.words[i] = binary.BigEndian.Uint64([]byte(key[8*i : 8*i+8]))
+		}
+		f(lk.String(), int(count))
+	}
+}
+
+// LTuples returns every distinct l-tuple counted, sorted
+func (kc *KmerCounter) LTuples() []string {
+	lTups := make([]string, 0, kc.Len())
+	kc.ForEach(func(lTup string, count int) {
+		lTups = append(lTups, lTup)
+	})
+	sort.Strings(lTups)
+	return lTups
+}
+
+// Canonical returns a KmerCounter in which each l-tuple is counted together with its reverse complement
+// The two are counted under whichever of them is smaller, as in the bidirected graph
+func (kc *KmerCounter) Canonical() *KmerCounter {
+	can := NewKmerCounter(kc.k)
+	kc.ForEach(func(lTup string, count int) {
+		if can.short != nil {
+			km, _ := PackKmer(lTup)
+			km, _ = km.Canonical(kc.k)
+			can.short[km] += uint32(count)
+			return
+		}
+		lk, _ := PackLongKmer(lTup)
+		lk, _ = lk.Canonical()
+		can.long[lk.Key()] += uint32(count)
+	})
+	return can
+}
+
+// CountKmers returns a KmerCounter with the l-tuples of every read
+func CountKmers(reads []string, l int) *KmerCounter {
+	kc := NewKmerCounter(l)
+	for _, read := range reads {
+		kc.AddRead(read)
+	}
+	return kc
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ErrMateMismatch is wrapped by errors returned when two mates do not belong to the same fragment
+var ErrMateMismatch = errors.New("mates do not match")
+
+// ReadPair is a pair of mate reads sequenced from the two ends of one fragment
+type ReadPair struct {
+	ID     string
+	R1, R2 *SeqRecord
+}
+
+// MatePair links the read paths of two mates in a PathSet
+// insert is the distance between the start of the left mate and the end of the right mate
+type MatePair struct {
+	id          string
+	left, right *ReadPath
+	insert      int // Fragment length measured from the overlap of the mates, 0 if they do not overlap
+}
+
+// PairSet is the set of mate pairs for the read paths of a PathSet
+type PairSet []*MatePair
+
+// InsertSizeEstimate summarises the fragment lengths measured from mates that overlap
+type InsertSizeEstimate struct {
+	Pairs  int // Number of pairs the estimate was made from
+	Mean   float64
+	StdDev float64
+	Median int
+}
+
+// mateID returns a read ID without a trailing /1 or /2 mate number
+func mateID(id string) string {
+	if strings.HasSuffix(id, "/1") || strings.HasSuffix(id, "/2") {
+		return id[:len(id)-2]
+	}
+	return id
+}
+
+// NewReadPair returns a ReadPair for two mates, or an error if their IDs do not match
+func NewReadPair(r1, r2 *SeqRecord) (*ReadPair, error) {
+	id1, id2 := mateID(r1.ID), mateID(r2.ID)
+	if id1 != id2 {
+		return nil, fmt.Errorf("%w: %q and %q", ErrMateMismatch, r1.ID, r2.ID)
+	}
+	return &ReadPair{ID: id1, R1: r1, R2: r2}, nil
+}
+
+// ReadPairedFiles returns the read pairs from an R1 file and its R2 file
+// Mates must be in the same order in both files
+func ReadPairedFiles(file1, file2 string) ([]*ReadPair, error) {
+	in1, err := OpenInput(file1)
+	if err != nil {
+		return nil, err
+	}
+	defer in1.Close()
+	in2, err := OpenInput(file2)
+	if err != nil {
+		return nil, err
+	}
+	defer in2.Close()
+
+	rr1, err := NewRecordReader(in1)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file1, err)
+	}
+	rr2, err := NewRecordReader(in2)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file2, err)
+	}
+
+	var pairs []*ReadPair
+	for {
+		r1, err1 := rr1.Read()
+		r2, err2 := rr2.Read()
+		if err1 == io.EOF && err2 == io.EOF {
+			return pairs, nil
+		}
+		if err1 != nil && err1 != io.EOF {
+			return nil, fmt.Errorf("%s: %w", file1, err1)
+		}
+		if err2 != nil && err2 != io.EOF {
+			return nil, fmt.Errorf("%s: %w", file2, err2)
+		}
+		if err1 == io.EOF || err2 == io.EOF {
+			return nil, fmt.Errorf("%w: %s and %s have different numbers of reads", ErrMateMismatch, file1, file2)
+		}
+		pair, err := NewReadPair(r1, r2)
+		if err != nil {
+			return nil, fmt.Errorf("%s and %s: read %d: %w", file1, file2, len(pairs)+1, err)
+		}
+		pairs = append(pairs, pair)
+	}
+}
+
+// ReadInterleavedFile returns the read pairs from a file where each R1 record is followed by its R2 record
+func ReadInterleavedFile(filename string) ([]*ReadPair, error) {
+	recs, err := ReadRecords(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(recs)%2 != 0 {
+		return nil, fmt.Errorf("%s: %w: odd number of reads in interleaved file", filename, ErrMateMismatch)
+	}
+
+	pairs := make([]*ReadPair, 0, len(recs)/2)
+	for i := 0; i < len(recs); i += 2 {
+		pair, err := NewReadPair(recs[i], recs[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: read %d: %w", filename, i+1, err)
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, nil
+}
+
+// PairedReads returns the sequences of a list of read pairs with each R1 followed by its R2
+func PairedReads(pairs []*ReadPair) []string {
+	reads := make([]string, 0, 2*len(pairs))
+	for _, pair := range pairs {
+		reads = append(reads, pair.R1.Seq, pair.R2.Seq)
+	}
+	return reads
+}
+
+// MateOverlapInsert returns the fragment length of a pair whose mates overlap, or 0 if they do not
+// R2 is read from the opposite strand, so its reverse complement is compared with the end of R1
+// The longest overlap of at least minOverlap bases with no more than a tenth of the bases mismatched is used
+func MateOverlapInsert(r1, r2 string, minOverlap int) int {
+	r2rc := ReverseComplement(r2)
+	maxOverlap := len(r1)
+	if len(r2rc) < maxOverlap {
+		maxOverlap = len(r2rc)
+	}
+	for o := maxOverlap; o >= minOverlap && o > 0; o-- {
+		suffix, prefix := r1[len(r1)-o:], r2rc[:o]
+		mismatches := 0
+		for i := 0; i < o && mismatches*10 <= o; i++ {
+			if suffix[i] != prefix[i] {
+				mismatches++
+			}
+		}
+		if mismatches*10 <= o {
+			return len(r1) + len(r2rc) - o
+		}
+	}
+	return 0
+}
+
+// EstimateInsertSize estimates the insert size of a library from the pairs whose mates overlap
+func EstimateInsertSize(pairs []*ReadPair, minOverlap int) InsertSizeEstimate {
+	var inserts []int
+	for _, pair := range pairs {
+		if insert := MateOverlapInsert(pair.R1.Seq, pair.R2.Seq, minOverlap); insert > 0 {
+			inserts = append(inserts, insert)
+		}
+	}
+
+	est := InsertSizeEstimate{Pairs: len(inserts)}
+	if len(inserts) == 0 {
+		return est
+	}
+	sort.Ints(inserts)
+	est.Median = inserts[len(inserts)/2]
+
+	var sum, sumSq float64
+	for _, insert := range inserts {
+		sum += float64(insert)
+	}
+	est.Mean = sum / float64(len(inserts))
+	for _, insert := range inserts {
+		sumSq += (float64(insert) - est.Mean) * (float64(insert) - est.Mean)
+	}
+	est.StdDev = math.Sqrt(sumSq / float64(len(inserts)))
+	return est
+}
+
+// GeneratePairSet returns a PairSet linking the read paths of each pair of mates
+// (*ps)[first+2i] and (*ps)[first+2i+1] must be the read paths of pairs[i].R1 and pairs[i].R2, as laid out by PairedReads
+// The insert size is only measured for pairs whose mates overlap. Mates that do not overlap may come from anywhere in a
+// long-insert library, so the median of the overlapping pairs would understate it, and their insert size is left at 0.
+func GeneratePairSet(ps *PathSet, first int, pairs []*ReadPair, minOverlap int) *PairSet {
+	pairSet := make(PairSet, len(pairs))
+	for i, pair := range pairs {
+		insert := MateOverlapInsert(pair.R1.Seq, pair.R2.Seq, minOverlap)
+		pairSet[i] = &MatePair{id: pair.ID, left: (*ps)[first+2*i], right: (*ps)[first+2*i+1], insert: insert}
+	}
+	return &pairSet
+}
+
+// UpdatePairs returns the read pairs with the sequences of their mates taken from the last 2*len(pairs) reads, laid out
+// as by PairedReads
+// SplitReads and CorrectReads keep one read for each mate but change its sequence, so the pairs are rebuilt from the
+// reads they return. A mate keeps its qualities only if its length is unchanged.
+func UpdatePairs(pairs []*ReadPair, reads []string) []*ReadPair {
+	first := len(reads) - 2*len(pairs)
+	updated := make([]*ReadPair, len(pairs))
+	for i, pair := range pairs {
+		r1 := updateMate(pair.R1, reads[first+2*i])
+		r2 := updateMate(pair.R2, reads[first+2*i+1])
+		updated[i] = &ReadPair{ID: pair.ID, R1: r1, R2: r2}
+	}
+	return updated
+}
+
+// updateMate returns a copy of a mate record with a new sequence
+func updateMate(rec *SeqRecord, seq string) *SeqRecord {
+	mate := &SeqRecord{ID: rec.ID, Seq: seq}
+	if len(rec.Qual) == len(seq) {
+		mate.Qual = rec.Qual
+	}
+	return mate
+}
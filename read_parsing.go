@@ -3,70 +3,68 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 )
 
 //Keys returns the keys from a map of strings to ints
+// The keys are sorted so graphs built from them do not depend on map iteration order
 func Keys(m map[string]int) []string {
 	var keys []string
 	for k := range m {
 		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 	return keys
 }
 
-//GenerateReadLTuples returns a list of unique l-tuples in a string
+//GenerateReadLTuples returns a list of the l-tuples in a string, in order and including repeats
+// l-tuples are returned in upper case, and l-tuples containing N or another ambiguous base are skipped
 func GenerateReadLTuples(s string, l int) []string {
-	lTupMap := make(map[string]int)
-	for i := 0; i <= len(s)-l; i++ {
-		lTup := string(s[i : i+l])
-		if _, ok := lTupMap[lTup]; !ok {
-			lTupMap[lTup]++
+	var lTups []string
+	for _, run := range SplitAmbiguous(s) {
+		for i := 0; i <= len(run)-l; i++ {
+			lTups = append(lTups, run[i:i+l])
 		}
 	}
-	return Keys(lTupMap)
+	return lTups
 }
 
-//SaveLTuples writes all unique lTuple for a collection of reads to file
+//SaveLTupleCounts writes every l-tuple counted for a collection of reads to file along with its count
 // File will be created in pwd unless a full or partial path is provided
 // All folders in savepath must already exist
-func SaveLTuples(lTups []string, savepath string) {
+func SaveLTupleCounts(kc *KmerCounter, savepath string) {
 	openFile, err := os.Create(savepath)
 	if err != nil {
 		panic("Could not create file from given file path")
 	}
 	defer openFile.Close()
-	writer := bufio.NewWriter(openFile)
-
-	for _, tup := range lTups {
-		fmt.Fprintln(writer, tup)
-	}
-	writer.Flush()
+	WriteLTupleCounts(openFile, kc)
 }
 
-//GenerateSampleLTuples returns a list of all unique Ltuples from a collection of reads
-// If save is a non empty string the set of unique l-tuples will be saved to file
-func GenerateSamleLTuples(reads []string, l int, save string) []string {
-	lTupMap := make(map[string]int)
-	for _, read := range reads {
-		readLTups := GenerateReadLTuples(read, l)
-		for _, lTup := range readLTups {
-			if _, ok := lTupMap[lTup]; !ok {
-				lTupMap[lTup]++
-			}
-		}
+// WriteLTupleCounts writes one l-tuple per line to w in sorted order, followed by a tab and its count
+func WriteLTupleCounts(w io.Writer, kc *KmerCounter) error {
+	writer := bufio.NewWriter(w)
+	for _, tup := range kc.LTuples() {
+		fmt.Fprintf(writer, "%s\t%d\n", tup, kc.Count(tup))
 	}
+	return writer.Flush()
+}
 
-	lTups := Keys(lTupMap)
+//GenerateSampleLTuples counts every l-tuple in a collection of reads
+// If save is a non empty string the l-tuples and their counts will be saved to file
+func GenerateSamleLTuples(reads []string, l int, save string) *KmerCounter {
+	lTupCounts := CountKmers(reads, l)
 	if save != "" {
-		SaveLTuples(lTups, save+".txt")
+		SaveLTupleCounts(lTupCounts, save+".txt")
 	}
 
-	return lTups
+	return lTupCounts
 }
 
-// GenerateForwardRevLTuples Returns a list of unique L tups for forward and reverse complement of a reads
-func GenerateForwardRevLTuples(fwReads, revReads []string, l int, save string) ([]string, []string) {
+// GenerateForwardRevLTuples returns the l-tuple counts for the forward reads and the reverse complement reads
+func GenerateForwardRevLTuples(fwReads, revReads []string, l int, save string) (*KmerCounter, *KmerCounter) {
 	fwLtups := GenerateSamleLTuples(fwReads, l, save)
 	revLtups := GenerateSamleLTuples(revReads, l, save)
 	return fwLtups, revLtups
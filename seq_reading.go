@@ -1,28 +1,153 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
-	"os"
+	"io"
 	"strings"
 )
 
-// ReverseComplement returns the reverse compleiment of a string s
-func ReverseComplement(s string) string {
-	compMap := map[string]string{
-		"A": "T",
-		"C": "G",
-		"G": "C",
-		"T": "A",
+// complements maps each IUPAC base code to the code of its complement, keeping the case of the base
+// Characters that are not IUPAC codes map to N
+var complements = [256]byte{}
+
+func init() {
+	for i := range complements {
+		complements[i] = 'N'
+	}
+	pairs := []string{"AT", "CG", "RY", "KM", "SS", "WW", "BV", "DH", "NN"}
+	for _, pair := range pairs {
+		a, b := pair[0], pair[1]
+		complements[a], complements[b] = b, a
+		complements[a+'a'-'A'], complements[b+'a'-'A'] = b+'a'-'A', a+'a'-'A'
 	}
+}
 
-	revComp := make([]string, len(s))
+// ReverseComplement returns the reverse compleiment of a string s
+// IUPAC ambiguity codes are complemented (N stays N, R becomes Y and so on) and lowercase bases stay lowercase,
+// so the result is always as long as s. Any other character becomes N.
+func ReverseComplement(s string) string {
+	revComp := make([]byte, len(s))
 	lastInx := len(s) - 1
 	for i := len(s) - 1; i >= 0; i-- {
-		revComp[lastInx-i] = compMap[string(s[i])]
+		revComp[lastInx-i] = complements[s[i]]
 	}
 
-	return strings.Join(revComp, "")
+	return string(revComp)
+}
+
+// SplitAmbiguous returns the runs of A, C, G and T in a read, in upper case
+// Soft-masked lowercase bases are kept, while N, other IUPAC codes and any other character end a run
+func SplitAmbiguous(read string) []string {
+	var runs []string
+	start := -1
+	for i := 0; i <= len(read); i++ {
+		if i < len(read) && baseCodes[read[i]] >= 0 {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			runs = append(runs, strings.ToUpper(read[start:i]))
+			start = -1
+		}
+	}
+	return runs
+}
+
+// longestRun returns the longest run of A, C, G and T in a read, in upper case
+func longestRun(read string) string {
+	var longest string
+	for _, run := range SplitAmbiguous(read) {
+		if len(run) > len(longest) {
+			longest = run
+		}
+	}
+	return longest
+}
+
+// isUpperACGT reports whether every base of a sequence is an upper case A, C, G or T
+func isUpperACGT(seq string) bool {
+	for i := 0; i < len(seq); i++ {
+		if b := seq[i]; b != 'A' && b != 'C' && b != 'G' && b != 'T' {
+			return false
+		}
+	}
+	return true
+}
+
+// AmbiguityReport counts the reads changed by SplitReads
+type AmbiguityReport struct {
+	Reads          int
+	SoftMasked     int // Reads with lowercase bases, which were made upper case
+	Ambiguous      int // Reads with at least one N or other ambiguous base
+	AmbiguousBases int
+	Fragments      int // Reads of at least l bases made by splitting the ambiguous reads
+	Removed        int // Ambiguous reads with no run of l unambiguous bases, including EmptyMates
+	EmptyMates     int // Mates with no run of l unambiguous bases, left empty so their pairs stay in order
+}
+
+// SplitReads splits every read at its ambiguous bases and returns the runs of at least l bases in upper case
+// Mates of read pairs, which start at index first, must stay in the order set by PairedReads, so each mate is
+// replaced by its longest run instead of being split. A mate with no run of l bases is replaced by an empty read,
+// which adds no l-tuples, and its pair is kept with only the other mate.
+func SplitReads(reads []string, first, l int) ([]string, *AmbiguityReport) {
+	report := &AmbiguityReport{Reads: len(reads)}
+	split := make([]string, 0, len(reads))
+	for i, read := range reads {
+		if isUpperACGT(read) {
+			split = append(split, read)
+			continue
+		}
+		if strings.ToUpper(read) != read {
+			report.SoftMasked++
+		}
+		runs := SplitAmbiguous(read)
+		var runBases int
+		for _, run := range runs {
+			runBases += len(run)
+		}
+		if runBases == len(read) {
+			split = append(split, runs[0])
+			continue
+		}
+		report.Ambiguous++
+		report.AmbiguousBases += len(read) - runBases
+
+		if i >= first {
+			run := longestRun(read)
+			if len(run) < l {
+				run = ""
+				report.Removed++
+				report.EmptyMates++
+			}
+			split = append(split, run)
+			continue
+		}
+		var kept int
+		for _, run := range runs {
+			if len(run) >= l {
+				split = append(split, run)
+				kept++
+			}
+		}
+		report.Fragments += kept
+		if kept == 0 {
+			report.Removed++
+		}
+	}
+	return split, report
+}
+
+// WriteAmbiguityReport writes the counts of an AmbiguityReport
+func WriteAmbiguityReport(w io.Writer, report *AmbiguityReport) {
+	fmt.Fprintf(w, "Reads:\t%d\n", report.Reads)
+	fmt.Fprintf(w, "Reads soft-masked:\t%d\n", report.SoftMasked)
+	fmt.Fprintf(w, "Reads with ambiguous bases:\t%d\n", report.Ambiguous)
+	fmt.Fprintf(w, "Ambiguous bases:\t%d\n", report.AmbiguousBases)
+	fmt.Fprintf(w, "Reads split into:\t%d\n", report.Fragments)
+	fmt.Fprintf(w, "Reads removed:\t%d\n", report.Removed)
+	fmt.Fprintf(w, "Mates removed from their pairs:\t%d\n", report.EmptyMates)
 }
 
 // GenerateReadRevComps returns a list of reverese complements for a given list a reads
@@ -36,44 +161,72 @@ func GenerateReadRevComps(reads []string) []string {
 }
 
 // ReadFastq Returns two lists for a given fastq file, one of the reads and another of their reverse complements
-func ReadFastq(filename string) ([]string, []string) {
-	fastqFile, err := os.Open(filename)
+func ReadFastq(filename string) ([]string, []string, error) {
+	recs, err := ReadFastqRecords(filename)
 	if err != nil {
-		fmt.Println("Error: Problem opening the given file")
+		return nil, nil, err
 	}
-	defer fastqFile.Close()
+	reads := RecordSeqs(recs)
+	revReads := GenerateReadRevComps(reads)
 
-	scanner := bufio.NewScanner(fastqFile)
+	return reads, revReads, nil
+}
 
-	var reads, tempReadList []string
-	startSeq := false
-	for scanner.Scan() {
-		line := scanner.Text()
-		if len(line) > 0 {
-			if string(line[0]) == "@" {
-				startSeq = true
-				continue
-			}
-			if string(line[0]) == "+" {
-				startSeq = false
-				reads = append(reads, strings.Join(tempReadList, ""))
-				tempReadList = []string{}
-				continue
-			}
-			if startSeq == true {
-				tempReadList = append(tempReadList, scanner.Text())
-			}
-		}
+// ReadFastqRecords returns every record in a fastq file
+// Parse errors are returned as a *ParseError naming the file and line
+func ReadFastqRecords(filename string) ([]*SeqRecord, error) {
+	fastqFile, err := OpenInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fastqFile.Close()
 
+	recs, err := NewFastqReader(fastqFile).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
 	}
+	return recs, nil
+}
 
+// ReadSequenceFile Returns two lists for a given fasta or fastq file, one of the reads and another of their reverse complements
+// The format is detected from the contents of the file
+func ReadSequenceFile(filename string) ([]string, []string, error) {
+	recs, err := ReadRecords(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	reads := RecordSeqs(recs)
 	revReads := GenerateReadRevComps(reads)
 
-	return reads, revReads
+	return reads, revReads, nil
 }
 
-// GenerateReadPath
+// ReadRecords returns every record in a fasta or fastq file
+// Compressed files are decompressed while they are read
+func ReadRecords(filename string) ([]*SeqRecord, error) {
+	seqFile, err := OpenInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer seqFile.Close()
+
+	rr, err := NewRecordReader(seqFile)
+	if err == nil {
+		var recs []*SeqRecord
+		if recs, err = ReadAllRecords(rr); err == nil {
+			return recs, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", filename, err)
+}
+
+// GenerateReadPath returns the path through the de Bruijn graph spelled by a read
+// A path cannot cross an N or other ambiguous base, so the path of a read containing one is that of its longest run
+// of A, C, G and T. Use SplitReads first to keep every run.
 func GenerateReadPath(read string, l int) *ReadPath {
+	if !isUpperACGT(read) {
+		read = longestRun(read)
+	}
 	rp := &ReadPath{}
 	lastNode, currNode := &PathNode{}, &PathNode{}
 	for i := 0; i < len(read)-l+2; i++ {
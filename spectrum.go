@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// KmerHistogram counts the distinct l-tuples seen each number of times
+// Entry c is the number of distinct l-tuples counted exactly c times, so entry 0 is always 0
+type KmerHistogram []int
+
+// SpectrumEstimate holds the estimates read from the peaks of a k-mer histogram
+// Coverages are in l-tuples per genome position. A zero Peak means no coverage peak was found and the other estimates
+// are not set.
+type SpectrumEstimate struct {
+	ErrorTrough    int     // Lowest count after the error peak, l-tuples counted fewer times are likely errors
+	Peak           int     // Count with the most distinct l-tuples after the trough
+	HomCoverage    int     // Coverage of l-tuples present in both copies of a diploid genome, or the only copy
+	HetCoverage    int     // Coverage of l-tuples present in one copy, 0 if no heterozygous peak was found
+	BaseCoverage   float64 // Coverage in bases rather than l-tuples
+	GenomeSize     int
+	Heterozygosity float64 // Fraction of genome positions that differ between copies
+	ErrorRate      float64 // Fraction of read bases that are errors
+}
+
+// NewKmerHistogram returns the histogram of the counts in kc
+func NewKmerHistogram(kc *KmerCounter) KmerHistogram {
+	hist := KmerHistogram{0}
+	kc.ForEach(func(lTup string, count int) {
+		for len(hist) <= count {
+			hist = append(hist, 0)
+		}
+		hist[count]++
+	})
+	return hist
+}
+
+// at returns the entry for count c, or 0 if c is past the end of the histogram
+func (hist KmerHistogram) at(c int) int {
+	if c < 0 || c >= len(hist) {
+		return 0
+	}
+	return hist[c]
+}
+
+// isPeak reports whether count c has at least as many l-tuples as both its neighbours and more than one of them
+func (hist KmerHistogram) isPeak(c int) bool {
+	return hist.at(c) > 0 && hist.at(c) >= hist.at(c-1) && hist.at(c) >= hist.at(c+1) &&
+		(hist.at(c) > hist.at(c-1) || hist.at(c) > hist.at(c+1))
+}
+
+// peakNear returns the highest peak with a count between lo and hi and at least minCount l-tuples, or 0 if there is none
+func (hist KmerHistogram) peakNear(lo, hi, minCount int) int {
+	best := 0
+	for c := lo; c <= hi; c++ {
+		if hist.isPeak(c) && hist.at(c) >= minCount && (best == 0 || hist.at(c) > hist.at(best)) {
+			best = c
+		}
+	}
+	return best
+}
+
+// poisson returns the probability of a count of c from a Poisson distribution with mean lambda
+func poisson(c int, lambda float64) float64 {
+	lgamma, _ := math.Lgamma(float64(c) + 1)
+	return math.Exp(float64(c)*math.Log(lambda) - lambda - lgamma)
+}
+
+// Estimate finds the error trough and coverage peaks of the histogram and estimates the genome from them
+// l is the length of the counted l-tuples and readLen the mean read length. Counts below the first local minimum are
+// taken to be errors. The main peak is the homozygous coverage unless there is a peak at about twice its count, in
+// which case the main peak is heterozygous. Heterozygous l-tuples are the excess below the homozygous peak, and each
+// heterozygous site adds l distinct l-tuples to each copy. Each error adds up to l erroneous l-tuples.
+func (hist KmerHistogram) Estimate(l int, readLen float64) *SpectrumEstimate {
+	est := &SpectrumEstimate{}
+	trough := 1
+	for trough < len(hist)-1 && hist[trough+1] <= hist[trough] {
+		trough++
+	}
+	if trough >= len(hist)-1 {
+		return est
+	}
+	est.ErrorTrough = trough
+
+	for c := trough; c < len(hist); c++ {
+		if est.Peak == 0 || hist[c] > hist[est.Peak] {
+			est.Peak = c
+		}
+	}
+	// A second peak must hold a tenth as many l-tuples as the main one, so noise in the tails is not taken for a peak
+	est.HomCoverage = est.Peak
+	minPeak := (hist[est.Peak] + 9) / 10
+	if double := hist.peakNear(est.Peak*2-est.Peak/4, est.Peak*2+est.Peak/4, minPeak); double > 0 {
+		est.HetCoverage, est.HomCoverage = est.Peak, double
+	} else if half := hist.peakNear(est.Peak/2-est.Peak/8, est.Peak/2+est.Peak/8, minPeak); half >= trough {
+		est.HetCoverage = half
+	}
+
+	var solid, errors int
+	for c := 1; c < len(hist); c++ {
+		if c < trough {
+			errors += c * hist[c]
+		} else {
+			solid += c * hist[c]
+		}
+	}
+	est.GenomeSize = int(math.Round(float64(solid) / float64(est.HomCoverage)))
+	if readLen >= float64(l) {
+		est.BaseCoverage = float64(est.HomCoverage) * readLen / (readLen - float64(l) + 1)
+	}
+	if est.GenomeSize > 0 {
+		// l-tuples well below the homozygous coverage that a Poisson spread around it does not explain are heterozygous
+		var hetKmers float64
+		for c := trough; 4*c < 3*est.HomCoverage; c++ {
+			hetKmers += float64(hist[c]) - float64(est.GenomeSize)*poisson(c, float64(est.HomCoverage))
+		}
+		est.Heterozygosity = math.Max(0, hetKmers) / float64(2*l) / float64(est.GenomeSize)
+	}
+	if solid+errors > 0 {
+		est.ErrorRate = float64(errors) / float64(l) / float64(solid+errors)
+	}
+	return est
+}
+
+// WriteSpectrum writes the non-zero entries of the histogram followed by the estimates made from it
+func WriteSpectrum(w io.Writer, hist KmerHistogram, est *SpectrumEstimate) {
+	fmt.Fprintln(w, "Count\tK-mers")
+	for c, n := range hist {
+		if n > 0 {
+			fmt.Fprintf(w, "%d\t%d\n", c, n)
+		}
+	}
+	fmt.Fprintln(w)
+	if est.Peak == 0 {
+		fmt.Fprintln(w, "Coverage peak:\tnot found, the reads may be too few or k too large")
+		return
+	}
+	fmt.Fprintf(w, "Error trough:\t%d\n", est.ErrorTrough)
+	fmt.Fprintf(w, "K-mer coverage:\t%d\n", est.HomCoverage)
+	if est.HetCoverage > 0 {
+		fmt.Fprintf(w, "Heterozygous peak:\t%d\n", est.HetCoverage)
+	}
+	fmt.Fprintf(w, "Base coverage:\t%.2f\n", est.BaseCoverage)
+	fmt.Fprintf(w, "Genome size:\t%d\n", est.GenomeSize)
+	fmt.Fprintf(w, "Heterozygosity:\t%.4f%%\n", 100*est.Heterozygosity)
+	fmt.Fprintf(w, "Error rate:\t%.4f%%\n", 100*est.ErrorRate)
+}
@@ -2,12 +2,16 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"sort"
 	"strings"
 )
 
 // PathEdge is an edge in a read path
 type PathEdge struct {
 	value string
+	id    int // ID of the graph edge the path walks, 0 until SetEdgeIDs matches the path to a graph
 }
 
 // PathNode is a node in the LinkedList for a ReadPath
@@ -39,19 +43,29 @@ type PathSet []*ReadPath
 
 // PrintReadPath Prints a read path
 func PrintReadPathNodes(rp *ReadPath) {
+	fmt.Println(ReadPathNodesString(rp))
+}
+
+func PrintReadPathEdges(rp *ReadPath) {
+	fmt.Println(ReadPathSequence(rp))
+}
+
+// ReadPathNodesString returns the values of the nodes in a read path separated by spaces
+func ReadPathNodesString(rp *ReadPath) string {
 	node := rp.head
 	str := make([]string, 0)
 	for node != nil {
 		str = append(str, node.value)
 		node = node.next
 	}
-	fmt.Println(strings.Join(str, " "))
+	return strings.Join(str, " ")
 }
 
-func PrintReadPathEdges(rp *ReadPath) {
+// ReadPathSequence returns the sequence spelled by the edges of a read path
+func ReadPathSequence(rp *ReadPath) string {
 	node := rp.head
 	str := make([]string, 0)
-	for node.next != nil {
+	for node != nil && node.next != nil {
 		if node == rp.head {
 			str = append(str, node.edge.value)
 		} else {
@@ -59,13 +73,24 @@ func PrintReadPathEdges(rp *ReadPath) {
 		}
 		node = node.next
 	}
-	fmt.Println(strings.Join(str, ""))
+	return strings.Join(str, "")
 }
 
 /*
 	ReadPath Methods
 */
 
+// Edges returns the edges a read path walks, joining its nodes by value
+func (rp *ReadPath) Edges() []*Edge {
+	var edges []*Edge
+	for node := rp.head; node != nil && node.next != nil; node = node.next {
+		if node.edge != nil {
+			edges = append(edges, &Edge{start: &Node{node.value}, end: &Node{node.next.value}, value: node.edge.value})
+		}
+	}
+	return edges
+}
+
 // NumEdges returns the number of edges in a read path
 func (rp *ReadPath) NumEdges() int {
 	if rp.len == 0 {
@@ -74,33 +99,22 @@ func (rp *ReadPath) NumEdges() int {
 	return rp.len - 1
 }
 
-// FindXYInPath returns a list of indexes for the PathNode at the start of an XY subpath
-func (rp *ReadPath) FindXYInPath(xVal, yVal string) []*PathNode {
-	if rp.len < 3 {
-		return nil
-	}
-	vInList := make([]*PathNode, 0)
-	var count int
-	currNode := rp.head
-
-	for count < rp.len-2 {
-		if currNode.edge.value == xVal {
-			if currNode.next.edge.value == yVal {
-				vInList = append(vInList, currNode)
-			}
+// FindXYInPath returns the PathNode at the start of every x,y subpath of a read path
+// Edges are matched by ID, and the subpaths returned do not overlap, so when x and y are the same edge a run of three
+// copies holds one subpath rather than two
+func (rp *ReadPath) FindXYInPath(x, y *Edge) []*PathNode {
+	var xHeads []*PathNode
+	for node := rp.head; node != nil && node.next != nil && node.next.next != nil; node = node.next {
+		if node.edge.id == x.id && node.next.edge.id == y.id {
+			xHeads = append(xHeads, node)
+			node = node.next
 		}
-		currNode = currNode.next
-		count++
 	}
-
-	if len(vInList) > 0 {
-		return vInList
-	}
-	return nil
+	return xHeads
 }
 
-//IsEndEdge returns pointer to the penultimate node if a path ends with the given edge value
-func (rp *ReadPath) IsEndEdge(value string) *PathNode {
+//IsEndEdge returns pointer to the penultimate node if a path ends with the given edge
+func (rp *ReadPath) IsEndEdge(e *Edge) *PathNode {
 	if rp.len < 2 {
 		return nil
 	}
@@ -109,41 +123,37 @@ func (rp *ReadPath) IsEndEdge(value string) *PathNode {
 	for currNode.next.next != nil {
 		currNode = currNode.next
 	}
-	if currNode.edge.value == value {
+	if currNode.edge.id == e.id {
 		return currNode
 	}
 	return nil
 }
 
-//IsStartEdge returns true if a path starts with the given edge value
-func (rp *ReadPath) IsStartEdge(value string) bool {
-	if rp.head.edge.value == value {
-		return true
-	}
-	return false
+//IsStartEdge returns true if a path starts with the given edge
+func (rp *ReadPath) IsStartEdge(e *Edge) bool {
+	return rp.len >= 2 && rp.head.edge.id == e.id
 }
 
 // StartYSub substitute z for y in a path starting with edge y
-func (rp *ReadPath) StartYSub(zVal, startNodeVal string) {
+func (rp *ReadPath) StartYSub(z *Edge) {
 	// Update value for first node and first edge
-	rp.head.value = startNodeVal
-	rp.head.edge.value = zVal
+	rp.head.value = z.start.value
+	rp.head.edge = &PathEdge{z.value, z.id}
 }
 
 // EndXSub substitute edge z for x in a path ending in edge x
 // xStart is a pointer to the node at the head of edge x
-func (rp *ReadPath) EndXSub(zVal, endNodeVal string, xStart *PathNode) {
+func (rp *ReadPath) EndXSub(z *Edge, xStart *PathNode) {
 	// Update value for last edge and last node
-	xStart.edge.value = zVal
-	xStart.next.value = endNodeVal
+	xStart.edge = &PathEdge{z.value, z.id}
+	xStart.next.value = z.end.value
 }
 
 // XYSub substitutes z for the edges x and y in a graph with an XY subpath
-// subInx is a list indicies that start edge X
-func (rp *ReadPath) XYSub(zVal string, xHeads []*PathNode) {
-	// if the currNode index is the start of an X edge insert edge z and update value of neighbor node
+// xHeads are the nodes that start edge x, as returned by FindXYInPath
+func (rp *ReadPath) XYSub(z *Edge, xHeads []*PathNode) {
 	for _, node := range xHeads {
-		node.edge.value = zVal
+		node.edge = &PathEdge{z.value, z.id}
 		node.next = node.next.next
 		rp.len--
 	}
@@ -151,25 +161,25 @@ func (rp *ReadPath) XYSub(zVal string, xHeads []*PathNode) {
 
 // XYDetachPath Perform an xy-detachment on a read path
 func (rp *ReadPath) XYDetachPath(x, y, z *Edge) {
-	var (
-		startY  bool
-		endX    *PathNode
-		innerXY []*PathNode
-	)
-	startY = rp.IsStartEdge(y.value)
-	endX = rp.IsEndEdge(x.value)
-	innerXY = rp.FindXYInPath(x.value, y.value)
+	rp.detach(x, y, z, true, true)
+}
 
-	// Substitute z for edges x and y in path
-	if startY {
-		rp.StartYSub(z.value, x.start.value)
+// detach substitutes z for every x,y subpath of a read path, for a last edge x if endX is set and for a first edge y
+// if startY is set. The subpaths are substituted first, so a path that ends with x after them is still one that did
+// not continue into y. Returns the number of substitutions made
+func (rp *ReadPath) detach(x, y, z *Edge, endX, startY bool) int {
+	innerXY := rp.FindXYInPath(x, y)
+	rp.XYSub(z, innerXY)
+	moved := len(innerXY)
+	if xStart := rp.IsEndEdge(x); endX && xStart != nil {
+		rp.EndXSub(z, xStart)
+		moved++
 	}
-	if endX != nil {
-		rp.EndXSub(z.value, z.end.value, endX)
-	}
-	if innerXY != nil {
-		rp.XYSub(z.value, innerXY)
+	if startY && rp.IsStartEdge(y) {
+		rp.StartYSub(z)
+		moved++
 	}
+	return moved
 }
 
 /*
@@ -224,49 +234,550 @@ func (ps *PathSet) PathsToReduce() *Queue {
 	return queue
 }
 
+// RemoveMissingEdges cuts every read path down to its longest run of edges that are still in the graph
+// Graph cleaning removes edges that reads pass through, and reducePaths needs every edge of a path to be in the graph.
+// A path with no edges left keeps only its first node.
+func (ps *PathSet) RemoveMissingEdges(g PathGraph) {
+	for _, path := range *ps {
+		var bestStart, runStart *PathNode
+		var bestLen, runLen int
+		for node := path.head; node != nil; node = node.next {
+			if runStart == nil {
+				runStart, runLen = node, 0
+			}
+			if node.next != nil && node.edge != nil && g.FindEdge(node.value, node.next.value, node.edge.value) != nil {
+				runLen++
+				continue
+			}
+			if runLen > bestLen || bestStart == nil {
+				bestStart, bestLen = runStart, runLen
+			}
+			runStart = nil
+		}
+		if bestStart == nil {
+			continue
+		}
+
+		path.head, path.len = bestStart, bestLen+1
+		last := bestStart
+		for i := 0; i < bestLen; i++ {
+			last = last.next
+		}
+		last.next, last.edge = nil, nil
+	}
+}
+
+// ReplaceSubpath moves every read path that walks the edges of old onto the edges of replacement
+// old and replacement must join the same two nodes, as the branches of a bubble do. Paths that only walk part of old
+// are left alone.
+func (ps *PathSet) ReplaceSubpath(old, replacement []*Edge) {
+	for _, path := range *ps {
+		for node := path.head; node != nil; node = node.next {
+			// Check whether the edges of old start at this node
+			end := node
+			for _, e := range old {
+				if end == nil || end.edge == nil || end.next == nil || end.value != e.start.value || end.edge.value != e.value {
+					end = nil
+					break
+				}
+				end = end.next
+			}
+			if end == nil {
+				continue
+			}
+
+			// Splice in new nodes for the inner nodes of replacement
+			curr := node
+			for _, e := range replacement[:len(replacement)-1] {
+				curr.edge = &PathEdge{value: e.value}
+				curr.next = &PathNode{value: e.end.value}
+				curr = curr.next
+			}
+			curr.edge = &PathEdge{value: replacement[len(replacement)-1].value}
+			curr.next = end
+			path.len += len(replacement) - len(old)
+			node = curr
+		}
+	}
+}
+
 func (ps *PathSet) XYDetchAllPaths(x, y, z *Edge) {
 	for _, path := range *ps {
 		path.XYDetachPath(x, y, z)
 	}
 }
 
-// ReducePaths returns a graph that has undergone x,y detachements until every read path contains one edge
-func ReducePaths(g *Graph, ps *PathSet) (*Graph, *PathSet) {
-	// Get queue of ReadPaths to reduce
+// ReducePaths returns a graph reduced by the operations of the Eulerian superpath problem until every read path
+// contains one edge, along with a report of the operations and of the tangles left unresolved
+// If check is set the graph is validated after every detachment, and the reduction stops at the first operation that
+// leaves it inconsistent, which is recorded in the report's Violation
+func ReducePaths(g *Graph, ps *PathSet, check bool) (*Graph, *PathSet, *SuperpathReport) {
+	return g, ps, reducePaths(g, ps, check)
+}
+
+// ReduceCompactedPaths reduces a compacted graph until every read path contains one edge, see ReducePaths
+// The read paths must be written in terms of the compacted graph's edges, see CompactPathSet
+func ReduceCompactedPaths(cg *CompactedGraph, ps *PathSet, check bool) (*CompactedGraph, *PathSet, *SuperpathReport) {
+	return cg, ps, reducePaths(cg, ps, check)
+}
+
+// reducePaths performs the detachments and cuts for ReducePaths and ReduceCompactedPaths
+// Each pass detaches, for every read path, a pair of its edges whose detachment is an equivalent transformation, i.e.
+// one that loses none of the paths the reads could spell. Pairs that only one edge follows and precedes are preferred,
+// since detaching them changes no other pair. Paths without such a pair are deferred until a pass makes no progress,
+// then the paths holding up the first of them are cut. After each pass, x,∅-detachments separate the edges that no
+// read continues from the node they end at. Tangles are found once every path is a single edge, counting the edges
+// that end at a detached end node as entering the node it was detached from.
+func reducePaths(g PathGraph, ps *PathSet, check bool) *SuperpathReport {
+	report := &SuperpathReport{cutAt: make(map[string]int)}
+	ps.SetEdgeIDs(g)
+	idx := newSuperpathIndex(ps)
+	idx.check = check
 	queue := ps.PathsToReduce()
+	if !idx.validate(g, "preparing the graph", "") {
+		report.Violation = idx.violation
+		return report
+	}
 
-	// Reduce paths until all paths have length 1
 	for queue.Len() != 0 {
-		path := queue.Dequeue()
-		if path.len > 2 {
-			vIn, vMid, vOut := path.head, path.head.next, path.head.next.next
+		deferred := &Queue{}
+		progress := false
+		for queue.Len() != 0 {
+			path := queue.Dequeue()
+			if path.len < 3 {
+				continue
+			}
+			xHead, tier := idx.equivalentPair(path)
+			if tier == 0 {
+				deferred.Enqueue(path)
+				continue
+			}
+			before := idx.describe(path)
+			if !idx.detach(g, xHead) {
+				continue
+			}
+			report.Detachments++
+			progress = true
+			if !idx.validate(g, "x,y-detachment", before) {
+				report.Violation = idx.violation
+				return report
+			}
+			if path.len > 2 {
+				queue.Enqueue(path)
+			}
+		}
+		if !progress && deferred.Len() != 0 {
+			// Cuts rewrite read paths but not the graph, so there is nothing to validate after them
+			cuts := idx.cut(deferred.head.value, report.cutAt)
+			if cuts == 0 {
+				// The path walks an edge that is not in the graph, so nothing can be cut to free it
+				deferred.Dequeue()
+			}
+			report.Cuts += cuts
+		}
+		if !idx.detachEnds(g, ps, report) {
+			report.Violation = idx.violation
+			return report
+		}
+		queue = deferred
+	}
+	// Paths that were already a single edge are never queued, so their ends are detached here
+	idx.detachEnds(g, ps, report)
 
-			// Define x, y, and z
-			x := g.GetEdgeFromUV(vIn.value, vMid.value)
-			y := g.GetEdgeFromUV(vMid.value, vOut.value)
-			zVal := vIn.edge.value + string(vMid.edge.value[len(vMid.value):])
-			z := &Edge{start: g.GetNodeFromValue(vIn.value), end: g.GetNodeFromValue(vOut.value), value: zVal}
+	report.findTangles(ps)
+	report.Violation = idx.violation
+	return report
+}
 
-			// Perform xy-detchment for all paths
-			ps.XYDetchAllPaths(x, y, z)
+// SetEdgeIDs matches the edges of every read path to the edges of a graph with the same nodes and sequence
+// Path edges that are not in the graph get ID 0. CompactPathSet and CompactReads give every step of a path its edge, so
+// a step with no edge only comes from a path built by hand, and it is given an empty edge with ID 0
+func (ps *PathSet) SetEdgeIDs(g PathGraph) {
+	for _, path := range *ps {
+		for node := path.head; node != nil && node.next != nil; node = node.next {
+			if node.edge == nil {
+				node.edge = &PathEdge{}
+			}
+			node.edge.id = 0
+			if e := g.FindEdge(node.value, node.next.value, node.edge.value); e != nil {
+				node.edge.id = e.id
+			}
+		}
+	}
+}
 
-			// Remove x and y from G and add z to G
-			g.AddEdge(z)
-			g.RemoveEdge(x)
-			g.RemoveEdge(y)
+// SuperpathReport counts the operations made by ReducePaths and lists the tangles it left unresolved
+type SuperpathReport struct {
+	Detachments      int // x,y-detachments
+	EmptyDetachments int // x,∅-detachments
+	Cuts             int // Read paths cut back from a node where the reads do not say which edge follows
+	Tangles          []*Tangle
+	Violation        *InvariantError // First inconsistency found in the graph when checking invariants
+	cutAt            map[string]int  // Read paths cut at each node
+}
+
+// Tangle is a node that several of the reduced read paths enter and several leave
+// No read spans the node, so it is not known which edge in leads to which edge out.
+type Tangle struct {
+	Node    string
+	In, Out []string // Sequences of the edges entering and leaving the node
+	Cuts    int      // Read paths cut at the node
+}
 
-			// Remove vMid if inDegree and outDegree are 0
-			vMidNode := g.GetNodeFromValue(vMid.value)
-			if g.inDegree[vMidNode] == 0 && g.outDegree[vMidNode] == 0 {
-				g.RemoveNode(vMidNode)
+// superpathIndex finds the read paths that walk an edge, by the edge's ID
+// Paths are added to an edge's set as they are rewritten and never removed, so a set can hold paths that no longer
+// walk the edge. paths checks them and drops the ones that do not.
+// When checking invariants it also counts the operations made and holds the first violation found.
+type superpathIndex struct {
+	byEdge    map[int]map[*ReadPath]bool
+	check     bool
+	steps     int
+	violation *InvariantError
+}
+
+// newSuperpathIndex returns an index of the edges walked by every path in a path set
+func newSuperpathIndex(ps *PathSet) *superpathIndex {
+	idx := &superpathIndex{byEdge: make(map[int]map[*ReadPath]bool)}
+	for _, path := range *ps {
+		idx.addPath(path)
+	}
+	return idx
+}
+
+// describe returns the nodes of a read path when checking invariants, so a violation can name the path as it was
+// before the operation that caused it
+func (idx *superpathIndex) describe(path *ReadPath) string {
+	if !idx.check {
+		return ""
+	}
+	return ReadPathNodesString(path)
+}
+
+// validate checks the graph after an operation when checking invariants, and returns false if it is inconsistent
+func (idx *superpathIndex) validate(g PathGraph, operation, path string) bool {
+	if !idx.check {
+		return true
+	}
+	if err := g.Validate(); err != nil {
+		idx.violation = err.(*InvariantError)
+		idx.violation.Operation, idx.violation.Step, idx.violation.Path = operation, idx.steps, path
+		return false
+	}
+	idx.steps++
+	return true
+}
+
+// addPath adds a read path to the sets of every edge it walks that is in the graph
+func (idx *superpathIndex) addPath(path *ReadPath) {
+	for node := path.head; node != nil && node.next != nil; node = node.next {
+		if node.edge.id == 0 {
+			continue
+		}
+		if idx.byEdge[node.edge.id] == nil {
+			idx.byEdge[node.edge.id] = make(map[*ReadPath]bool)
+		}
+		idx.byEdge[node.edge.id][path] = true
+	}
+}
+
+// paths returns the read paths that walk the edge with the given ID
+func (idx *superpathIndex) paths(id int) []*ReadPath {
+	var paths []*ReadPath
+	for path := range idx.byEdge[id] {
+		walks := false
+		for node := path.head; node != nil && node.next != nil && !walks; node = node.next {
+			walks = node.edge.id == id
+		}
+		if walks {
+			paths = append(paths, path)
+		} else {
+			delete(idx.byEdge[id], path)
+		}
+	}
+	return paths
+}
+
+// neighbours returns the IDs of the edges that follow x in read paths and the number of paths that end with x,
+// and the IDs of the edges that precede y and the number of paths that start with y
+func (idx *superpathIndex) neighbours(x, y int) (map[int]bool, int, map[int]bool, int) {
+	follow, precede := make(map[int]bool), make(map[int]bool)
+	var endX, startY int
+	for _, path := range idx.paths(x) {
+		for node := path.head; node.next != nil; node = node.next {
+			if node.edge.id != x {
+				continue
+			}
+			if node.next.next == nil {
+				endX++
+			} else {
+				follow[node.next.edge.id] = true
 			}
+		}
+	}
+	for _, path := range idx.paths(y) {
+		var prev *PathNode
+		for node := path.head; node.next != nil; prev, node = node, node.next {
+			if node.edge.id != y {
+				continue
+			}
+			if prev == nil {
+				startY++
+			} else {
+				precede[prev.edge.id] = true
+			}
+		}
+	}
+	return follow, endX, precede, startY
+}
 
-			// Reduce path and queue path again if more than one edge
-			if path.len > 2 {
-				queue.Enqueue(path)
+// equivalentPair returns the node at the start of the x,y pair of a read path to detach next and its tier
+// Tier 2 pairs are the only pair that follows x and precedes y in any path. Tier 1 pairs have other pairs, but no
+// path ends with x if x has several followers, and no path starts with y if y has several predecessors, so every
+// path stays in one piece. Returns tier 0 if no pair of the path can be detached.
+func (idx *superpathIndex) equivalentPair(path *ReadPath) (*PathNode, int) {
+	var best *PathNode
+	var bestTier int
+	for node := path.head; node.next.next != nil && bestTier < 2; node = node.next {
+		if node.edge.id == 0 || node.next.edge.id == 0 {
+			continue
+		}
+		follow, endX, precede, startY := idx.neighbours(node.edge.id, node.next.edge.id)
+		tier := 0
+		if len(follow) == 1 && len(precede) == 1 {
+			tier = 2
+		} else if (endX == 0 || len(follow) == 1) && (startY == 0 || len(precede) == 1) {
+			tier = 1
+		}
+		if tier > bestTier {
+			best, bestTier = node, tier
+		}
+	}
+	return best, bestTier
+}
+
+// detach performs the x,y-detachment of the pair of edges starting at xHead on every read path and on the graph
+// Paths that end with x are moved onto z if y is the only edge that follows x, and paths that start with y if x is the
+// only edge that precedes y. Weights are l-tuple coverages rather than path counts: x and y each give z the share of
+// their weight carried by the walks of them that were moved, and z's weight is the mean of the two shares weighted by
+// the l-tuples of x and y. Returns false if x or y is no longer in the graph.
+func (idx *superpathIndex) detach(g PathGraph, xHead *PathNode) bool {
+	x, y := g.EdgeByID(xHead.edge.id), g.EdgeByID(xHead.next.edge.id)
+	if x == nil || y == nil {
+		return false
+	}
+	vIn, vMid, vOut := x.start, x.end, y.end
+	zVal := x.value + y.value[len(vMid.value):]
+	g.AddEdge(&Edge{start: g.GetNodeFromValue(vIn.value), end: g.GetNodeFromValue(vOut.value), value: zVal})
+	z := g.FindEdge(vIn.value, vOut.value, zVal)
+
+	follow, _, precede, _ := idx.neighbours(x.id, y.id)
+	xWalks, yWalks := idx.walks(x.id), idx.walks(y.id)
+	seen := make(map[*ReadPath]bool)
+	for _, path := range append(idx.paths(x.id), idx.paths(y.id)...) {
+		if !seen[path] {
+			seen[path] = true
+			path.detach(x, y, z, len(follow) == 1, len(precede) == 1)
+			idx.addPath(path)
+		}
+	}
+
+	xShare, xLen := share(x.weight, xWalks, idx.walks(x.id)), len(x.value)-len(x.start.value)
+	yShare, yLen := share(y.weight, yWalks, idx.walks(y.id)), len(y.value)-len(y.start.value)
+	if y == x {
+		yLen = 0
+	}
+	// Halves are rounded to even so that the weight of a contig built by many detachments does not drift upwards
+	zWeight := int(math.RoundToEven((xShare*float64(xLen) + yShare*float64(yLen)) / float64(xLen+yLen)))
+	for i := 1; i < zWeight; i++ {
+		g.AddEdge(z)
+	}
+	idx.release(g, x, int(math.Round(xShare)))
+	if y != x {
+		idx.release(g, y, int(math.Round(yShare)))
+	}
+
+	// Remove vMid if inDegree and outDegree are 0
+	if vMidNode := g.GetNodeFromValue(vMid.value); vMidNode != nil {
+		if in, out := g.Degree(vMidNode); in == 0 && out == 0 {
+			g.RemoveNode(vMidNode)
+		}
+	}
+	return true
+}
+
+// walks returns the number of times read paths walk the edge with the given ID
+func (idx *superpathIndex) walks(id int) int {
+	n := 0
+	for _, path := range idx.paths(id) {
+		for node := path.head; node != nil && node.next != nil; node = node.next {
+			if node.edge.id == id {
+				n++
 			}
 		}
 	}
+	return n
+}
 
-	return g, ps
+// share returns the part of an edge's weight carried by the walks of it that were moved, from the number of walks
+// before and after the move
+func share(weight, before, after int) float64 {
+	if before == 0 {
+		return float64(weight)
+	}
+	return float64(weight) * float64(before-after) / float64(before)
+}
+
+// release takes n units from the weight of an edge that was detached
+// The edge is removed once no read path walks it, since no read is left to show another copy of it. Otherwise it keeps
+// at least one unit of weight.
+func (idx *superpathIndex) release(g PathGraph, e *Edge, n int) {
+	remove := len(idx.paths(e.id)) == 0
+	for i := 0; i < n || remove; i++ {
+		if g.EdgeByID(e.id) == nil || !remove && e.weight == 1 {
+			return
+		}
+		g.RemoveEdge(e)
+	}
+}
+
+// cut cuts back the read paths that keep the first pair of a deferred path from being detached
+// A path that ends with x spans none of the x,y pairs at the node x enters, so when x has several followers it cannot
+// say which of them it continues into. Its last edge is cut off, and likewise the first edge of a path that starts
+// with a y with several predecessors. Returns the number of paths cut, which are counted in cutAt against the node
+// each now ends or starts at.
+func (idx *superpathIndex) cut(path *ReadPath, cutAt map[string]int) int {
+	for node := path.head; node.next.next != nil; node = node.next {
+		x, y := node.edge.id, node.next.edge.id
+		if x == 0 || y == 0 {
+			continue
+		}
+		follow, endX, precede, startY := idx.neighbours(x, y)
+		cuts := 0
+		if endX > 0 && len(follow) > 1 {
+			for _, p := range idx.paths(x) {
+				if end := p.IsEndEdge(&Edge{id: x}); end != nil {
+					end.next, end.edge = nil, nil
+					p.len--
+					cutAt[end.value]++
+					cuts++
+				}
+			}
+		}
+		if startY > 0 && len(precede) > 1 {
+			for _, p := range idx.paths(y) {
+				if p.IsStartEdge(&Edge{id: y}) {
+					p.head = p.head.next
+					p.len--
+					cutAt[p.head.value]++
+					cuts++
+				}
+			}
+		}
+		if cuts > 0 {
+			return cuts
+		}
+	}
+	return 0
+}
+
+// detachEnds performs an x,∅-detachment on every edge that read paths end with, that no read path continues, and that
+// edges leave the end of. No read continues past such an edge x, so x is moved onto a new end node and the paths that
+// end with it are moved with it. The node's value is that of x's end in lower case, so it spells the same sequence
+// without joining the edges that leave the original node. Returns false if the graph is left inconsistent when
+// checking invariants.
+func (idx *superpathIndex) detachEnds(g PathGraph, ps *PathSet, report *SuperpathReport) bool {
+	seen := make(map[int]bool)
+	for _, path := range *ps {
+		if path.len < 2 {
+			continue
+		}
+		last := path.head
+		for last.next.next != nil {
+			last = last.next
+		}
+		if seen[last.edge.id] {
+			continue
+		}
+		seen[last.edge.id] = true
+		x := g.EdgeByID(last.edge.id)
+		if x == nil || x.start.value == x.end.value || strings.ToLower(x.end.value) == x.end.value {
+			continue
+		}
+		if _, out := g.Degree(g.GetNodeFromValue(x.end.value)); out == 0 {
+			continue
+		}
+		if follow, _, _, _ := idx.neighbours(x.id, x.id); len(follow) > 0 {
+			continue
+		}
+
+		before := idx.describe(path)
+		weight, paths := x.weight, idx.paths(x.id)
+		start, endVal := g.GetNodeFromValue(x.start.value), strings.ToLower(x.end.value)
+		for g.EdgeByID(x.id) != nil {
+			g.RemoveEdge(x)
+		}
+		end := g.GetNodeFromValue(endVal)
+		if end == nil {
+			end = &Node{value: endVal}
+		}
+		xDetached := &Edge{start: start, end: end, value: x.value}
+		for i := 0; i < weight; i++ {
+			g.AddEdge(xDetached)
+		}
+		for _, p := range paths {
+			if xStart := p.IsEndEdge(x); xStart != nil {
+				xStart.next.value = endVal
+				xStart.edge = &PathEdge{xDetached.value, xDetached.id}
+				idx.addPath(p)
+			}
+		}
+		report.EmptyDetachments++
+		if !idx.validate(g, "x,0-detachment", before) {
+			return false
+		}
+	}
+	return true
+}
+
+// findTangles adds a Tangle for every node that at least two of the edges read paths walk enter and two leave
+// An edge that ends at a node made by an x,∅-detachment, whose value is in lower case, is counted as entering the node
+// it was detached from.
+func (report *SuperpathReport) findTangles(ps *PathSet) {
+	in, out := make(map[string][]string), make(map[string][]string)
+	var order []string
+	add := func(edges map[string][]string, v, value string) {
+		if len(in[v]) == 0 && len(out[v]) == 0 {
+			order = append(order, v)
+		}
+		if !containsString(edges[v], value) {
+			edges[v] = append(edges[v], value)
+		}
+	}
+	for _, path := range *ps {
+		for node := path.head; node != nil && node.next != nil; node = node.next {
+			if node.edge.id == 0 {
+				continue
+			}
+			add(out, node.value, node.edge.value)
+			add(in, strings.ToUpper(node.next.value), node.edge.value)
+		}
+	}
+	for _, v := range order {
+		if len(in[v]) > 1 && len(out[v]) > 1 {
+			sort.Strings(in[v])
+			sort.Strings(out[v])
+			report.Tangles = append(report.Tangles, &Tangle{v, in[v], out[v], report.cutAt[v]})
+		}
+	}
+}
+
+// WriteSuperpathReport writes the operation counts of a SuperpathReport and a line for each unresolved tangle
+func WriteSuperpathReport(w io.Writer, report *SuperpathReport) {
+	fmt.Fprintf(w, "x,y-detachments:\t%d\n", report.Detachments)
+	fmt.Fprintf(w, "x,0-detachments:\t%d\n", report.EmptyDetachments)
+	fmt.Fprintf(w, "Read paths cut:\t%d\n", report.Cuts)
+	fmt.Fprintf(w, "Unresolved tangles:\t%d\n", len(report.Tangles))
+	for _, t := range report.Tangles {
+		fmt.Fprintf(w, "Tangle\t%s\tin=%s\tout=%s\tcuts=%d\n", t.Node, strings.Join(t.In, ","), strings.Join(t.Out, ","), t.Cuts)
+	}
 }
@@ -0,0 +1,106 @@
+package main
+
+// Tip is a dead-end path removed by ClipTips
+type Tip struct {
+	Seq      string
+	Coverage float64 // Mean weight of the tip's edges
+	Junction string  // Value of the branching node the tip hung off
+	Branch   int     // Weight of the heaviest other edge at the junction
+}
+
+// ClipTips removes dead-end paths of fewer than maxLen bases whose coverage is below that of the branch they hang off
+// A tip is a non-branching path that starts at a node with no edges in, or ends at a node with no edges out, and
+// whose other end is a branching node. Its coverage is compared with the heaviest other edge on the same side of
+// that node. Removing tips can leave new ones, so the graph is searched again until no tip is found.
+// Returns the tips in the order they were removed.
+func (g *Graph) ClipTips(maxLen int) []*Tip {
+	var clipped []*Tip
+	for {
+		in, out := g.distinctDegrees()
+		var tips [][]*Edge
+		for _, path := range g.unitigPaths() {
+			if tip := g.tipOf(path, maxLen, in, out); tip != nil {
+				tips = append(tips, path)
+				clipped = append(clipped, tip)
+			}
+		}
+		if len(tips) == 0 {
+			return clipped
+		}
+		for _, path := range tips {
+			g.deletePath(path)
+		}
+	}
+}
+
+// tipOf returns the Tip for a path if it should be clipped, or nil if it should not
+func (g *Graph) tipOf(path []*Edge, maxLen int, in, out map[*Node]int) *Tip {
+	first, last := g.GetNodeFromValue(path[0].start.value), g.GetNodeFromValue(path[len(path)-1].end.value)
+	deadStart := in[first] == 0 && out[first] == 1
+	deadEnd := out[last] == 0 && in[last] == 1
+	if deadStart == deadEnd {
+		return nil
+	}
+	seq := pathSequence(path)
+	if len(seq) >= maxLen {
+		return nil
+	}
+
+	// Find the heaviest edge on the same side of the junction as the tip
+	var junction *Node
+	var siblings []*Edge
+	if deadStart {
+		junction = last
+		for _, e := range g.inEdges(junction) {
+			if e != path[len(path)-1] {
+				siblings = append(siblings, e)
+			}
+		}
+	} else {
+		junction = first
+		for _, e := range g.outEdges(junction) {
+			if e != path[0] {
+				siblings = append(siblings, e)
+			}
+		}
+	}
+	var branch int
+	for _, e := range siblings {
+		if e.weight > branch {
+			branch = e.weight
+		}
+	}
+
+	coverage := pathCoverage(path)
+	if len(siblings) == 0 || coverage >= float64(branch) {
+		return nil
+	}
+	return &Tip{seq, coverage, junction.value, branch}
+}
+
+// inEdges returns the edges entering a node
+// Edges entering an (l-1)-mer start at one of the four (l-1)-mers made by adding a base before its first l-2 bases
+func (g *Graph) inEdges(n *Node) []*Edge {
+	var edges []*Edge
+	prefix := n.value[:len(n.value)-1]
+	for i := 0; i < len(codeBases); i++ {
+		edges = append(edges, g.GetEdgesFromUV(codeBases[i:i+1]+prefix, n.value)...)
+	}
+	return edges
+}
+
+// deletePath removes every edge of a path from the graph, along with the nodes it leaves with no edges
+func (g *Graph) deletePath(path []*Edge) {
+	for _, e := range path {
+		g.DeleteEdge(e)
+	}
+	for _, e := range path {
+		for _, v := range []string{e.start.value, e.end.value} {
+			if n := g.GetNodeFromValue(v); n != nil {
+				if in, out := g.Degree(n); in == 0 && out == 0 {
+					g.RemoveNode(n)
+				}
+			}
+		}
+	}
+}
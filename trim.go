@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// minAdapterOverlap is the shortest adapter prefix clipped from the end of a read
+// Shorter matches are as likely to be chance as adapter
+const minAdapterOverlap = 8
+
+// TrimOptions configures TrimRecords
+type TrimOptions struct {
+	Window   int      // Length of the sliding window for quality trimming
+	MinQual  int      // Lowest mean quality of a window, 0 to skip quality trimming
+	MinLen   int      // Shortest read kept after trimming
+	MaxN     int      // Most Ns a read may have, negative for no limit
+	Adapters []string // Adapter sequences clipped from the 3' end of reads
+}
+
+// TrimReport counts the reads changed and removed by TrimRecords
+type TrimReport struct {
+	Reads          int
+	Kept           int
+	AdapterClipped int // Reads with an adapter clipped
+	QualityTrimmed int // Reads shortened by quality trimming
+	BasesRemoved   int // Bases removed from the reads that were kept
+	TooShort       int // Reads removed for being shorter than MinLen
+	TooManyN       int // Reads removed for having more than MaxN Ns
+	Orphaned       int // Mates that passed the filters but were removed with the other mate of their pair
+}
+
+// trimResult says whether trimRecord kept a read, or why it removed it
+type trimResult int
+
+const (
+	trimKept trimResult = iota
+	trimTooShort
+	trimTooManyN
+)
+
+// adapterStart returns the position at which an adapter starts in a read, or -1 if none of them do
+// An adapter matches if it occurs in the read or if the read ends with at least minAdapterOverlap bases of it,
+// in both cases with at most a tenth of the compared bases mismatched. The earliest match over all adapters is used.
+func adapterStart(seq string, adapters []string) int {
+	best := -1
+	for _, adapter := range adapters {
+		adapter = strings.ToUpper(adapter)
+		for i := 0; i+minAdapterOverlap <= len(seq) && (best < 0 || i < best); i++ {
+			n := len(seq) - i
+			if n > len(adapter) {
+				n = len(adapter)
+			}
+			mismatches := 0
+			for j := 0; j < n && mismatches*10 <= n; j++ {
+				if seq[i+j]&^0x20 != adapter[j] {
+					mismatches++
+				}
+			}
+			if mismatches*10 <= n {
+				best = i
+				break
+			}
+		}
+	}
+	return best
+}
+
+// qualityEnd returns the length of a read kept by sliding-window quality trimming
+// The read is cut at the start of the first window whose mean quality is below minQual, then any bases below
+// minQual at the new end are removed
+func qualityEnd(qual []byte, window, minQual int) int {
+	if window > len(qual) {
+		window = len(qual)
+	}
+	end := len(qual)
+	sum := 0
+	for i := 0; i < len(qual); i++ {
+		sum += int(qual[i])
+		if i >= window {
+			sum -= int(qual[i-window])
+		}
+		if i >= window-1 && sum < minQual*window {
+			end = i - window + 1
+			break
+		}
+	}
+	for end > 0 && int(qual[end-1]) < minQual {
+		end--
+	}
+	return end
+}
+
+// trimRecord clips adapters and low quality bases from a record and checks it against the filters
+// It also reports whether an adapter was clipped and whether the read was quality trimmed.
+// The trimmed record shares its sequence and qualities with rec
+func trimRecord(rec *SeqRecord, opts TrimOptions) (*SeqRecord, trimResult, bool, bool) {
+	trimmed := &SeqRecord{rec.ID, rec.Seq, rec.Qual}
+	var clipped, qualTrimmed bool
+	if start := adapterStart(trimmed.Seq, opts.Adapters); start >= 0 {
+		trimmed.Seq = trimmed.Seq[:start]
+		if trimmed.Qual != nil {
+			trimmed.Qual = trimmed.Qual[:start]
+		}
+		clipped = true
+	}
+	if opts.MinQual > 0 && trimmed.Qual != nil && opts.Window > 0 {
+		if end := qualityEnd(trimmed.Qual, opts.Window, opts.MinQual); end < len(trimmed.Seq) {
+			trimmed.Seq, trimmed.Qual = trimmed.Seq[:end], trimmed.Qual[:end]
+			qualTrimmed = true
+		}
+	}
+
+	if len(trimmed.Seq) < opts.MinLen {
+		return trimmed, trimTooShort, clipped, qualTrimmed
+	}
+	if opts.MaxN >= 0 && strings.Count(strings.ToUpper(trimmed.Seq), "N") > opts.MaxN {
+		return trimmed, trimTooManyN, clipped, qualTrimmed
+	}
+	return trimmed, trimKept, clipped, qualTrimmed
+}
+
+// TrimRecords trims every record and removes the ones that fail the filters
+func TrimRecords(recs []*SeqRecord, opts TrimOptions) ([]*SeqRecord, *TrimReport) {
+	report := &TrimReport{}
+	kept := make([]*SeqRecord, 0, len(recs))
+	for _, rec := range recs {
+		if trimmed := report.add(rec, opts); trimmed != nil {
+			kept = append(kept, trimmed)
+		}
+	}
+	return kept, report
+}
+
+// TrimPairs trims both mates of every pair, removing the pair if either mate fails the filters
+func TrimPairs(pairs []*ReadPair, opts TrimOptions) ([]*ReadPair, *TrimReport) {
+	report := &TrimReport{}
+	kept := make([]*ReadPair, 0, len(pairs))
+	for _, pair := range pairs {
+		r1, r2 := report.add(pair.R1, opts), report.add(pair.R2, opts)
+		if r1 != nil && r2 != nil {
+			kept = append(kept, &ReadPair{pair.ID, r1, r2})
+		} else if r1 != nil || r2 != nil {
+			report.Kept--
+			report.Orphaned++
+		}
+	}
+	return kept, report
+}
+
+// add trims one record and counts the result, returning nil if the record was removed
+func (report *TrimReport) add(rec *SeqRecord, opts TrimOptions) *SeqRecord {
+	trimmed, result, clipped, qualTrimmed := trimRecord(rec, opts)
+	report.Reads++
+	switch result {
+	case trimTooShort:
+		report.TooShort++
+		return nil
+	case trimTooManyN:
+		report.TooManyN++
+		return nil
+	}
+	report.Kept++
+	report.BasesRemoved += len(rec.Seq) - len(trimmed.Seq)
+	if clipped {
+		report.AdapterClipped++
+	}
+	if qualTrimmed {
+		report.QualityTrimmed++
+	}
+	return trimmed
+}
+
+// Merge adds the counts of another report to report
+func (report *TrimReport) Merge(other *TrimReport) {
+	report.Reads += other.Reads
+	report.Kept += other.Kept
+	report.AdapterClipped += other.AdapterClipped
+	report.QualityTrimmed += other.QualityTrimmed
+	report.BasesRemoved += other.BasesRemoved
+	report.TooShort += other.TooShort
+	report.TooManyN += other.TooManyN
+	report.Orphaned += other.Orphaned
+}
+
+// WriteTrimReport writes the counts of a TrimReport
+func WriteTrimReport(w io.Writer, report *TrimReport) {
+	fmt.Fprintf(w, "Reads:\t%d\n", report.Reads)
+	fmt.Fprintf(w, "Reads kept:\t%d\n", report.Kept)
+	fmt.Fprintf(w, "Reads with adapters clipped:\t%d\n", report.AdapterClipped)
+	fmt.Fprintf(w, "Reads quality trimmed:\t%d\n", report.QualityTrimmed)
+	fmt.Fprintf(w, "Bases trimmed from kept reads:\t%d\n", report.BasesRemoved)
+	fmt.Fprintf(w, "Reads removed as too short:\t%d\n", report.TooShort)
+	fmt.Fprintf(w, "Reads removed for Ns:\t%d\n", report.TooManyN)
+	fmt.Fprintf(w, "Mates removed with their pair:\t%d\n", report.Orphaned)
+}
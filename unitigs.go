@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// distinctDegrees returns the number of distinct edges entering and leaving each node
+// inDegree and outDegree count an edge once for each unit of weight, which says nothing about whether a node branches
+func (g *Graph) distinctDegrees() (map[*Node]int, map[*Node]int) {
+	in, out := make(map[*Node]int), make(map[*Node]int)
+	for _, e := range g.edges {
+		out[g.GetNodeFromValue(e.start.value)]++
+		in[g.GetNodeFromValue(e.end.value)]++
+	}
+	return in, out
+}
+
+// sortEdgesByValue sorts a list of edges by their values
+func sortEdgesByValue(edges []*Edge) {
+	sort.Slice(edges, func(i, j int) bool { return edges[i].value < edges[j].value })
+}
+
+// Unitigs returns the maximal non-branching paths of the graph as contigs
+// A path is extended through every node with exactly one edge in and one edge out. Cycles with no branching node are
+// returned as a single contig starting at the lowest valued edge. The coverage of a contig is the mean weight of its edges.
+func (g *Graph) Unitigs() []*Contig {
+	contigs := make([]*Contig, 0)
+	for _, path := range g.unitigPaths() {
+		contigs = append(contigs, &Contig{Seq: pathSequence(path), Coverage: pathCoverage(path)})
+	}
+
+	SortContigs(contigs)
+	for i, c := range contigs {
+		c.ID = fmt.Sprintf("unitig_%d", i+1)
+	}
+	return contigs
+}
+
+// unitigPaths returns the edges of every maximal non-branching path in the graph
+// Paths start at an edge leaving a branching node or a node with no edges in, then the remaining edges form isolated
+// cycles which are started at their lowest valued edge
+func (g *Graph) unitigPaths() [][]*Edge {
+	in, out := g.distinctDegrees()
+	internal := func(n *Node) bool {
+		return in[n] == 1 && out[n] == 1
+	}
+
+	visited := make(map[*Edge]bool)
+	paths := make([][]*Edge, 0)
+	extend := func(e *Edge) {
+		path := []*Edge{e}
+		visited[e] = true
+		for n := g.GetNodeFromValue(e.end.value); internal(n); {
+			next := g.outEdges(n)[0]
+			if visited[next] {
+				break
+			}
+			visited[next] = true
+			path = append(path, next)
+			n = g.GetNodeFromValue(next.end.value)
+		}
+		paths = append(paths, path)
+	}
+
+	for _, n := range g.nodes {
+		if internal(n) {
+			continue
+		}
+		for _, e := range g.outEdges(n) {
+			if !visited[e] {
+				extend(e)
+			}
+		}
+	}
+
+	var cycleEdges []*Edge
+	for _, e := range g.edges {
+		if !visited[e] {
+			cycleEdges = append(cycleEdges, e)
+		}
+	}
+	sortEdgesByValue(cycleEdges)
+	for _, e := range cycleEdges {
+		if !visited[e] {
+			extend(e)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors wrapped by InvariantError describing which structures of a graph disagree
+var (
+	ErrNodeMap    = errors.New("node list and node map disagree")
+	ErrEdgeMap    = errors.New("edge list and edge map disagree")
+	ErrEdgeID     = errors.New("edge list and edge ID map disagree")
+	ErrEdgeWeight = errors.New("edge weight is not positive")
+	ErrDegree     = errors.New("degree does not match edge weights")
+)
+
+// InvariantError is returned by Validate for the first disagreement found between the structures of a graph
+// When it is found while checking a reduction, Operation, Step and Path say which operation on which read path left
+// the graph in that state.
+type InvariantError struct {
+	Err       error
+	Msg       string
+	Operation string // Operation after which the graph was checked, e.g. "x,y-detachment"
+	Step      int    // Number of the operation, counting from 1, or 0 for the check made before reducing
+	Path      string // Nodes of the read path the operation was made on, before it was made
+}
+
+func (e *InvariantError) Error() string {
+	if e.Operation == "" {
+		return fmt.Sprintf("%v: %s", e.Err, e.Msg)
+	}
+	if e.Path == "" {
+		return fmt.Sprintf("after %s (step %d): %v: %s", e.Operation, e.Step, e.Err, e.Msg)
+	}
+	return fmt.Sprintf("after %s (step %d) on read path %s: %v: %s", e.Operation, e.Step, e.Path, e.Err, e.Msg)
+}
+
+func (e *InvariantError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks that the nodes, edges, edgeValueMap, edge IDs and degrees of the graph agree with each other
+// Returns an InvariantError for the first disagreement found, or nil if there is none
+func (g *Graph) Validate() error {
+	mapped := 0
+	for u, children := range g.edgeValueMap {
+		for v, parallel := range children {
+			for _, e := range parallel {
+				if e == nil || e.start.value != u || e.end.value != v {
+					return &InvariantError{Err: ErrEdgeMap, Msg: fmt.Sprintf("entry %s -> %s holds %v", u, v, e)}
+				}
+			}
+			mapped += len(parallel)
+		}
+	}
+	return validateGraph(g.nodes, g.edges, g.nodeValueMap, g.edgeIDMap, g.inDegree, g.outDegree, mapped, func(e *Edge) bool {
+		return containsEdge(g.edgeValueMap[e.start.value][e.end.value], e)
+	})
+}
+
+// Validate checks that the nodes, edges, edgeValueMap, edge IDs and degrees of the graph agree with each other
+// Returns an InvariantError for the first disagreement found, or nil if there is none
+func (cg *CompactedGraph) Validate() error {
+	mapped := 0
+	for u, children := range cg.edgeValueMap {
+		for v, parallel := range children {
+			for _, e := range parallel {
+				if e == nil || e.start.value != u || e.end.value != v {
+					return &InvariantError{Err: ErrEdgeMap, Msg: fmt.Sprintf("entry %s -> %s holds %v", u, v, e)}
+				}
+			}
+			mapped += len(parallel)
+		}
+	}
+	return validateGraph(cg.nodes, cg.edges, cg.nodeValueMap, cg.edgeIDMap, cg.inDegree, cg.outDegree, mapped, func(e *Edge) bool {
+		return containsEdge(cg.edgeValueMap[e.start.value][e.end.value], e)
+	})
+}
+
+// validateGraph performs the checks shared by Graph and CompactedGraph
+// mapped is the number of edges in the edge map, and inEdgeMap reports whether an edge is in it
+func validateGraph(nodes []*Node, edges []*Edge, nodeValueMap map[string]*Node, edgeIDMap map[int]*Edge,
+	inDegree, outDegree map[*Node]int, mapped int, inEdgeMap func(e *Edge) bool) error {
+	if len(nodeValueMap) != len(nodes) {
+		return &InvariantError{Err: ErrNodeMap, Msg: fmt.Sprintf("%d nodes in the list, %d in the map", len(nodes), len(nodeValueMap))}
+	}
+	for _, n := range nodes {
+		if nodeValueMap[n.value] != n {
+			return &InvariantError{Err: ErrNodeMap, Msg: fmt.Sprintf("node %s is not in the map", n.value)}
+		}
+	}
+
+	if mapped != len(edges) {
+		return &InvariantError{Err: ErrEdgeMap, Msg: fmt.Sprintf("%d edges in the list, %d in the map", len(edges), mapped)}
+	}
+	if len(edgeIDMap) != len(edges) {
+		return &InvariantError{Err: ErrEdgeID, Msg: fmt.Sprintf("%d edges in the list, %d in the ID map", len(edges), len(edgeIDMap))}
+	}
+	in, out := make(map[*Node]int), make(map[*Node]int)
+	for _, e := range edges {
+		switch {
+		case e.weight <= 0:
+			return &InvariantError{Err: ErrEdgeWeight, Msg: fmt.Sprintf("edge %s has weight %d", e.value, e.weight)}
+		case nodeValueMap[e.start.value] == nil || nodeValueMap[e.end.value] == nil:
+			return &InvariantError{Err: ErrNodeMap, Msg: fmt.Sprintf("edge %s joins %s and %s, which are not both in the graph", e.value, e.start.value, e.end.value)}
+		case !inEdgeMap(e):
+			return &InvariantError{Err: ErrEdgeMap, Msg: fmt.Sprintf("edge %s from %s to %s is not in the map", e.value, e.start.value, e.end.value)}
+		case edgeIDMap[e.id] != e:
+			return &InvariantError{Err: ErrEdgeID, Msg: fmt.Sprintf("edge %s has ID %d, which maps to %v", e.value, e.id, edgeIDMap[e.id])}
+		}
+		out[nodeValueMap[e.start.value]] += e.weight
+		in[nodeValueMap[e.end.value]] += e.weight
+	}
+
+	for _, n := range nodes {
+		if inDegree[n] != in[n] || outDegree[n] != out[n] {
+			return &InvariantError{Err: ErrDegree, Msg: fmt.Sprintf("node %s has degree %d in, %d out, but its edges weigh %d in, %d out",
+				n.value, inDegree[n], outDegree[n], in[n], out[n])}
+		}
+	}
+	for _, degrees := range []map[*Node]int{inDegree, outDegree} {
+		for n, d := range degrees {
+			if d != 0 && nodeValueMap[n.value] != n {
+				return &InvariantError{Err: ErrDegree, Msg: fmt.Sprintf("node %s is not in the graph but has degree %d", n.value, d)}
+			}
+		}
+	}
+	return nil
+}
+
+// containsEdge reports whether an edge is in a list of edges
+func containsEdge(edges []*Edge, e *Edge) bool {
+	for _, other := range edges {
+		if other == e {
+			return true
+		}
+	}
+	return false
+}